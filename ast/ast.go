@@ -9,6 +9,7 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position
 }
 
 type Statement interface {
@@ -33,6 +34,13 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -51,6 +59,9 @@ func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
+func (ls *LetStatement) Pos() token.Position {
+	return ls.Token.Pos
+}
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -67,9 +78,15 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
+// Identifier is Local once the resolver has found it names a variable of a
+// function or catch block: Slot in the environment Depth levels out from
+// where it appears. Other identifiers are looked up by name.
 type Identifier struct {
 	Token token.Token
 	Value string
+	Local bool
+	Depth int
+	Slot  int
 }
 
 func (i *Identifier) expressionNode() {}
@@ -77,6 +94,9 @@ func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
 
 func (i *Identifier) String() string {
 	return i.Value
@@ -92,6 +112,9 @@ func (rs *ReturnStatement) statementNode() {}
 func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
+func (rs *ReturnStatement) Pos() token.Position {
+	return rs.Token.Pos
+}
 
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
@@ -105,6 +128,30 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+type ThrowStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {}
+func (ts *ThrowStatement) TokenLiteral() string {
+	return ts.Token.Literal
+}
+func (ts *ThrowStatement) Pos() token.Position {
+	return ts.Token.Pos
+}
+func (ts *ThrowStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ts.TokenLiteral() + " ")
+	if ts.Value != nil {
+		out.WriteString(ts.Value.String())
+	}
+	out.WriteString(";")
+
+	return out.String()
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -115,6 +162,9 @@ func (es *ExpressionStatement) statementNode() {}
 func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
+func (es *ExpressionStatement) Pos() token.Position {
+	return es.Token.Pos
+}
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -122,9 +172,12 @@ func (es *ExpressionStatement) String() string {
 	return ""
 }
 
+// IntegerLiteral's Object, filled in by the resolver, is the value the
+// literal evaluates to, made once and shared by every evaluation.
 type IntegerLiteral struct {
-	Token token.Token
-	Value int64
+	Token  token.Token
+	Value  int64
+	Object interface{}
 }
 
 func (li *IntegerLiteral) expressionNode() {}
@@ -132,6 +185,9 @@ func (li *IntegerLiteral) expressionNode() {}
 func (li *IntegerLiteral) TokenLiteral() string {
 	return li.Token.Literal
 }
+func (li *IntegerLiteral) Pos() token.Position {
+	return li.Token.Pos
+}
 func (li *IntegerLiteral) String() string {
 	return li.Token.Literal
 }
@@ -146,6 +202,9 @@ func (pe *PrefixExpression) expressionNode() {}
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
+func (pe *PrefixExpression) Pos() token.Position {
+	return pe.Token.Pos
+}
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -168,6 +227,9 @@ func (ie *InfixExpression) expressionNode() {}
 func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *InfixExpression) Pos() token.Position {
+	return ie.Left.Pos()
+}
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -189,6 +251,9 @@ func (b *Boolean) expressionNode() {}
 func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Pos
+}
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -204,6 +269,9 @@ func (ie *IfExpression) expressionNode() {}
 func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *IfExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -220,15 +288,59 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// TryExpression has a Catch block, a Finally block or both; Parameter is the
+// name the caught error is bound to inside Catch. CatchLocals names the slots
+// of the catch block's environment like FunctionLiteral's Locals.
+type TryExpression struct {
+	Token       token.Token
+	Block       *BlockStatement
+	Parameter   *Identifier
+	Catch       *BlockStatement
+	Finally     *BlockStatement
+	CatchLocals []string
+}
+
+func (te *TryExpression) expressionNode() {}
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+func (te *TryExpression) Pos() token.Position {
+	return te.Token.Pos
+}
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+
+	if te.Catch != nil {
+		out.WriteString("catch(")
+		out.WriteString(te.Parameter.String())
+		out.WriteString(") ")
+		out.WriteString(te.Catch.String())
+	}
+	if te.Finally != nil {
+		out.WriteString("finally ")
+		out.WriteString(te.Finally.String())
+	}
+
+	return out.String()
+}
+
+// BlockStatement's End is the position of its closing brace.
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	End        token.Position
 }
 
 func (bs *BlockStatement) statementNode() {}
 func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -239,16 +351,23 @@ func (bs *BlockStatement) String() string {
 	return out.String()
 }
 
+// FunctionLiteral's Locals, filled in by the resolver, names the slots of its
+// environment: the parameters, then the variables its body declares.
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Name       string
+	Locals     []string
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
@@ -265,16 +384,24 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// CallExpression is Tail when the enclosing function returns its result
+// directly, so the call can reuse the caller's frame. End is the position of
+// its closing parenthesis.
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	Tail      bool
+	End       token.Position
 }
 
 func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
+func (ce *CallExpression) Pos() token.Position {
+	return ce.Function.Pos()
+}
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -290,9 +417,12 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// StringLiteral's Object, like IntegerLiteral's, is filled in by the
+// resolver.
 type StringLiteral struct {
-	Token token.Token
-	Value string
+	Token  token.Token
+	Value  string
+	Object interface{}
 }
 
 func (sl *StringLiteral) expressionNode() {}
@@ -300,19 +430,55 @@ func (sl *StringLiteral) expressionNode() {}
 func (sl *StringLiteral) TokenLiteral() string {
 	return sl.Token.Literal
 }
+func (sl *StringLiteral) Pos() token.Position {
+	return sl.Token.Pos
+}
 func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
 
+type TemplateLiteral struct {
+	Token       token.Token
+	Strings     []string
+	Expressions []Expression
+}
+
+func (tl *TemplateLiteral) expressionNode() {}
+func (tl *TemplateLiteral) TokenLiteral() string {
+	return tl.Token.Literal
+}
+func (tl *TemplateLiteral) Pos() token.Position {
+	return tl.Token.Pos
+}
+func (tl *TemplateLiteral) String() string {
+	var out bytes.Buffer
+
+	for i, s := range tl.Strings {
+		out.WriteString(s)
+		if i < len(tl.Expressions) {
+			out.WriteString("${")
+			out.WriteString(tl.Expressions[i].String())
+			out.WriteString("}")
+		}
+	}
+
+	return out.String()
+}
+
+// ArrayLiteral's End is the position of its closing bracket.
 type ArrayLiteral struct {
 	Token    token.Token
 	Elements []Expression
+	End      token.Position
 }
 
 func (ai *ArrayLiteral) expressionNode() {}
 func (ai *ArrayLiteral) TokenLiteral() string {
 	return ai.Token.Literal
 }
+func (ai *ArrayLiteral) Pos() token.Position {
+	return ai.Token.Pos
+}
 func (ai *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -337,6 +503,9 @@ func (ie *IndexExpression) expressionNode() {}
 func (ie *IndexExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *IndexExpression) Pos() token.Position {
+	return ie.Left.Pos()
+}
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -349,15 +518,52 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+type SliceExpression struct {
+	Token token.Token
+	Left  Expression
+	Start Expression
+	End   Expression
+}
+
+func (se *SliceExpression) expressionNode() {}
+func (se *SliceExpression) TokenLiteral() string {
+	return se.Token.Literal
+}
+func (se *SliceExpression) Pos() token.Position {
+	return se.Left.Pos()
+}
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// HashLiteral's End is the position of its closing brace.
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	End   token.Position
 }
 
 func (hl *HashLiteral) expressionNode() {}
 func (hl *HashLiteral) TokenLiteral() string {
 	return hl.Token.Literal
 }
+func (hl *HashLiteral) Pos() token.Position {
+	return hl.Token.Pos
+}
 
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
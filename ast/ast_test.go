@@ -1,6 +1,7 @@
 package ast
 
 import (
+	"bytes"
 	"monkey/token"
 	"testing"
 )
@@ -26,3 +27,47 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong, got=%q", result)
 	}
 }
+
+func TestFprint(t *testing.T) {
+	pos := func(column int) token.Position {
+		return token.Position{Line: 1, Column: column}
+	}
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let", Pos: pos(1)},
+				Name:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: "x", Pos: pos(5)}, Value: "x"},
+				Value: &InfixExpression{
+					Token:    token.Token{Type: token.PLUS, Literal: "+", Pos: pos(11)},
+					Left:     &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1", Pos: pos(9)}, Value: 1},
+					Operator: "+",
+					Right: &CallExpression{
+						Token:     token.Token{Type: token.LPAREN, Literal: "(", Pos: pos(16)},
+						Function:  &Identifier{Token: token.Token{Type: token.IDENT, Literal: "len", Pos: pos(13)}, Value: "len"},
+						Arguments: []Expression{&StringLiteral{Token: token.Token{Type: token.STRING, Literal: "a", Pos: pos(17)}, Value: "a"}},
+					},
+				},
+			},
+			&ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return", Pos: pos(22)}},
+		},
+	}
+
+	expected := `Program 1:1
+  LetStatement 1:1
+    Name: Identifier x 1:5
+    Value: InfixExpression + 1:9
+      Left: IntegerLiteral 1 1:9
+      Right: CallExpression 1:13
+        Function: Identifier len 1:13
+        Arguments[0]: StringLiteral "a" 1:17
+  ReturnStatement 1:22
+`
+
+	var out bytes.Buffer
+	if err := Fprint(&out, program); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out.String() != expected {
+		t.Errorf("Fprint wrong, expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+}
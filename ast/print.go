@@ -0,0 +1,150 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fprint writes node to w as a tree, one node per line with its position and
+// children indented beneath it, to show what the parser made of some source.
+func Fprint(w io.Writer, node Node) error {
+	var out bytes.Buffer
+	fprint(&out, 0, "", node)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+type child struct {
+	label string
+	node  Node
+}
+
+func fprint(out *bytes.Buffer, depth int, label string, node Node) {
+	detail, children := describe(node)
+
+	out.WriteString(strings.Repeat("  ", depth))
+	if label != "" {
+		out.WriteString(label + ": ")
+	}
+	out.WriteString(strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast."))
+	if detail != "" {
+		out.WriteString(" " + detail)
+	}
+	out.WriteString(" " + node.Pos().String() + "\n")
+
+	for _, c := range children {
+		fprint(out, depth+1, c.label, c.node)
+	}
+}
+
+// describe returns what to show of node besides its type and position, and
+// the children to print beneath it. Optional children that are missing are
+// left out.
+func describe(node Node) (string, []child) {
+	switch node := node.(type) {
+	case *Program:
+		return "", statements(node.Statements)
+	case *BlockStatement:
+		return "", statements(node.Statements)
+	case *LetStatement:
+		return "", []child{{"Name", node.Name}, {"Value", node.Value}}
+	case *ReturnStatement:
+		if node.ReturnValue == nil {
+			return "", nil
+		}
+		return "", []child{{"Value", node.ReturnValue}}
+	case *ThrowStatement:
+		return "", []child{{"Value", node.Value}}
+	case *ExpressionStatement:
+		return "", []child{{"", node.Expression}}
+	case *Identifier:
+		return node.Value, nil
+	case *IntegerLiteral:
+		return node.Token.Literal, nil
+	case *Boolean:
+		return node.Token.Literal, nil
+	case *StringLiteral:
+		return strconv.Quote(node.Value), nil
+	case *TemplateLiteral:
+		return strconv.Quote(node.Token.Literal), list("Expressions", node.Expressions)
+	case *PrefixExpression:
+		return node.Operator, []child{{"Right", node.Right}}
+	case *InfixExpression:
+		return node.Operator, []child{{"Left", node.Left}, {"Right", node.Right}}
+	case *IfExpression:
+		children := []child{{"Condition", node.Condition}, {"Consequence", node.Consequence}}
+		if node.Alternative != nil {
+			children = append(children, child{"Alternative", node.Alternative})
+		}
+		return "", children
+	case *TryExpression:
+		children := []child{{"Block", node.Block}}
+		if node.Catch != nil {
+			children = append(children, child{"Parameter", node.Parameter}, child{"Catch", node.Catch})
+		}
+		if node.Finally != nil {
+			children = append(children, child{"Finally", node.Finally})
+		}
+		return "", children
+	case *FunctionLiteral:
+		var children []child
+		for i, param := range node.Parameters {
+			children = append(children, child{fmt.Sprintf("Parameters[%d]", i), param})
+		}
+		return node.Name, append(children, child{"Body", node.Body})
+	case *CallExpression:
+		detail := ""
+		if node.Tail {
+			detail = "tail"
+		}
+		return detail, append([]child{{"Function", node.Function}}, list("Arguments", node.Arguments)...)
+	case *ArrayLiteral:
+		return "", list("Elements", node.Elements)
+	case *IndexExpression:
+		return "", []child{{"Left", node.Left}, {"Index", node.Index}}
+	case *SliceExpression:
+		children := []child{{"Left", node.Left}}
+		if node.Start != nil {
+			children = append(children, child{"Start", node.Start})
+		}
+		if node.End != nil {
+			children = append(children, child{"End", node.End})
+		}
+		return "", children
+	case *HashLiteral:
+		keys := make([]Expression, 0, len(node.Pairs))
+		for key := range node.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := keys[i].Pos(), keys[j].Pos()
+			return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+		})
+		var children []child
+		for _, key := range keys {
+			children = append(children, child{"Key", key}, child{"Value", node.Pairs[key]})
+		}
+		return "", children
+	}
+	return "", nil
+}
+
+func statements(stmts []Statement) []child {
+	children := make([]child, len(stmts))
+	for i, s := range stmts {
+		children[i] = child{node: s}
+	}
+	return children
+}
+
+func list(label string, exps []Expression) []child {
+	children := make([]child, len(exps))
+	for i, exp := range exps {
+		children[i] = child{fmt.Sprintf("%s[%d]", label, i), exp}
+	}
+	return children
+}
@@ -0,0 +1,121 @@
+package monkey
+
+import (
+	"errors"
+	"fmt"
+	"monkey/evaluator"
+	"monkey/object"
+	"reflect"
+)
+
+var (
+	errorType       = reflect.TypeOf((*error)(nil)).Elem()
+	environmentType = reflect.TypeOf((*object.Environment)(nil))
+)
+
+// BindFunc wraps an arbitrary Go function as a builtin. Arguments are
+// converted with FromObject and results with ToObject. The function may take
+// a leading *object.Environment, may be variadic, and may return nothing, a
+// value, an error, or a value and an error; a non-nil error or a panic
+// becomes a Monkey error. Returning an *object.Error sets its kind.
+func BindFunc(name string, fn interface{}) (*object.Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("cannot bind %T as a builtin", fn)
+	}
+	return bindFunc(name, v)
+}
+
+// RegisterFunc binds fn with BindFunc and registers it under name.
+func (i *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	builtin, err := BindFunc(name, fn)
+	if err != nil {
+		return err
+	}
+	i.env.Runtime().Builtins[name] = builtin
+	return nil
+}
+
+func bindFunc(name string, fn reflect.Value) (*object.Builtin, error) {
+	ft := fn.Type()
+	switch {
+	case ft.NumOut() > 2:
+		return nil, fmt.Errorf("cannot bind %s: too many results", ft)
+	case ft.NumOut() == 2 && ft.Out(1) != errorType:
+		return nil, fmt.Errorf("cannot bind %s: second result must be error", ft)
+	}
+
+	offset := 0
+	if ft.NumIn() > 0 && ft.In(0) == environmentType {
+		offset = 1
+	}
+	fixed := ft.NumIn() - offset
+	if ft.IsVariadic() {
+		fixed--
+	}
+
+	return &object.Builtin{
+		Name: name,
+		Fn: func(env *object.Environment, args ...object.Object) (result object.Object) {
+			if len(args) < fixed || !ft.IsVariadic() && len(args) != fixed {
+				return newError(object.ArityError, "`%s` takes %d argument(s), got %d", name, fixed, len(args))
+			}
+
+			in := make([]reflect.Value, 0, offset+len(args))
+			if offset == 1 {
+				in = append(in, reflect.ValueOf(env))
+			}
+			for i, arg := range args {
+				var t reflect.Type
+				if i < fixed {
+					t = ft.In(offset + i)
+				} else {
+					t = ft.In(ft.NumIn() - 1).Elem()
+				}
+				value := reflect.New(t).Elem()
+				if err := fromObject(arg, value); err != nil {
+					return newError(object.TypeError, "argument %d to `%s`: %s", i+1, name, err)
+				}
+				in = append(in, value)
+			}
+
+			defer func() {
+				if r := recover(); r != nil {
+					result = newError("", "`%s` panicked: %v", name, r)
+				}
+			}()
+			return callResult(name, fn.Call(in))
+		},
+	}, nil
+}
+
+func callResult(name string, out []reflect.Value) object.Object {
+	if len(out) > 0 && out[len(out)-1].Type() == errorType {
+		if err := out[len(out)-1]; !err.IsNil() {
+			return hostError(err.Interface().(error))
+		}
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return evaluator.NULL
+	}
+	obj, err := toObject(out[0], nil)
+	if err != nil {
+		return newError(object.TypeError, "result of `%s`: %s", name, err)
+	}
+	return obj
+}
+
+// hostError converts an error returned by a bound function, keeping the kind
+// and details of an *object.Error but not its stack.
+func hostError(err error) *object.Error {
+	var objErr *object.Error
+	if errors.As(err, &objErr) {
+		return &object.Error{Kind: objErr.Kind, Message: objErr.Message, Details: objErr.Details}
+	}
+	return newError("", "%s", err)
+}
+
+func newError(kind string, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is how many unchanged lines are shown around each change.
+const diffContext = 3
+
+// edit is one line of a diff: kept, removed from a or added from b. a and b
+// count the lines of each that come before it.
+type edit struct {
+	kind byte
+	line string
+	a, b int
+}
+
+// unifiedDiff describes the changes that turn a into b, line by line, in
+// the unified format of diff -u.
+func unifiedDiff(name string, a, b []byte) string {
+	edits := diffLines(splitLines(string(a)), splitLines(string(b)))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s.orig\n+++ %s\n", name, name)
+	for i := 0; i < len(edits); {
+		if edits[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := hunkEnd(edits, i)
+		writeHunk(&out, edits[start:end])
+		i = end
+	}
+	return out.String()
+}
+
+// hunkEnd returns where the hunk holding the change at i ends: after the
+// context following its last change, taking in later changes whose context
+// would overlap.
+func hunkEnd(edits []edit, i int) int {
+	end := i
+	for end < len(edits) {
+		if edits[end].kind != ' ' {
+			end++
+			continue
+		}
+		next := end
+		for next < len(edits) && edits[next].kind == ' ' {
+			next++
+		}
+		if next == len(edits) || next-end > 2*diffContext {
+			break
+		}
+		end = next
+	}
+	if end+diffContext < len(edits) {
+		return end + diffContext
+	}
+	return len(edits)
+}
+
+func writeHunk(out *strings.Builder, hunk []edit) {
+	var aCount, bCount int
+	for _, e := range hunk {
+		if e.kind != '+' {
+			aCount++
+		}
+		if e.kind != '-' {
+			bCount++
+		}
+	}
+	aStart, bStart := hunk[0].a, hunk[0].b
+	if aCount > 0 {
+		aStart++
+	}
+	if bCount > 0 {
+		bStart++
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+	for _, e := range hunk {
+		out.WriteByte(e.kind)
+		out.WriteString(e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// diffLines lines up x and y along their longest common subsequence.
+func diffLines(x, y []string) []edit {
+	// lcs[i][j] is the length of the longest common subsequence of x[i:]
+	// and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var edits []edit
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			edits = append(edits, edit{' ', x[i], i, j})
+			i++
+			j++
+		case j == len(y) || i < len(x) && lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, edit{'-', x[i], i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', y[j], i, j})
+			j++
+		}
+	}
+	return edits
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
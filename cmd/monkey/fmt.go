@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"monkey/format"
+	"os"
+)
+
+// runFmt formats the files named in args, or standard input if there are
+// none. It returns 1 when -d finds source that needs formatting, and 2 when
+// some cannot be read, parsed or written.
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write the result back to each file instead of printing it")
+	diff := flags.Bool("d", false, "print a diff of the changes instead of the result")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "cannot use -w with standard input")
+			return 2
+		}
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		return formatFile("<stdin>", src, false, *diff, stdout, stderr)
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			status = 2
+			continue
+		}
+		if s := formatFile(path, src, *write, *diff, stdout, stderr); s > status {
+			status = s
+		}
+	}
+	return status
+}
+
+func formatFile(path string, src []byte, write, diff bool, stdout, stderr io.Writer) int {
+	formatted, err := format.Source(src)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %s\n", path, err)
+		return 2
+	}
+	changed := !bytes.Equal(src, formatted)
+
+	if diff && changed {
+		io.WriteString(stdout, unifiedDiff(path, src, formatted))
+	}
+	if !diff && !write {
+		stdout.Write(formatted)
+	}
+	if write && changed {
+		if err := os.WriteFile(path, formatted, 0644); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+	if diff && changed && !write {
+		return 1
+	}
+	return 0
+}
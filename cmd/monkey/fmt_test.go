@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\nadded\n13\n14\n15"
+
+	expected := `--- x.mk.orig
++++ x.mk
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -10,6 +10,7 @@
+ 10
+ 11
+ 12
++added
+ 13
+ 14
+-15
++15
+\ No newline at end of file
+`
+	if got := unifiedDiff("x.mk", []byte(a), []byte(b)); got != expected {
+		t.Errorf("wrong diff, expected=\n%s\ngot=\n%s", expected, got)
+	}
+}
+
+func TestRunFmt(t *testing.T) {
+	dir := t.TempDir()
+	messy := filepath.Join(dir, "messy.mk")
+	tidy := filepath.Join(dir, "tidy.mk")
+	broken := filepath.Join(dir, "broken.mk")
+	os.WriteFile(messy, []byte("let x=1\n"), 0644)
+	os.WriteFile(tidy, []byte("let x = 1;\n"), 0644)
+	os.WriteFile(broken, []byte("let = 1\n"), 0644)
+
+	tests := []struct {
+		args     []string
+		stdin    string
+		status   int
+		expected string
+	}{
+		{nil, "let y=2", 0, "let y = 2;\n"},
+		{[]string{messy, tidy}, "", 0, "let x = 1;\nlet x = 1;\n"},
+		{[]string{"-d", tidy}, "", 0, ""},
+		{[]string{"-d", messy}, "", 1, "--- " + messy + ".orig\n+++ " + messy + "\n@@ -1,1 +1,1 @@\n-let x=1\n+let x = 1;\n"},
+		{[]string{"-d", broken, tidy}, "", 2, ""},
+		{[]string{"-w"}, "", 2, ""},
+		{[]string{"-w", messy}, "", 0, ""},
+		{[]string{"-d", messy}, "", 0, ""},
+	}
+
+	for i, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		status := runFmt(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+		if status != tt.status {
+			t.Errorf("tests[%d] wrong status, expected=%d, got=%d (%s)", i, tt.status, status, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("tests[%d] wrong output, expected=%q, got=%q", i, tt.expected, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"monkey/diag"
+	"monkey/lint"
+	"os"
+	"strings"
+)
+
+// runLint reports the problems in the files named in args, showing the lines
+// they are on, or with -json as a JSON array that also holds the errors of
+// files that do not parse. It returns 1 when it finds any problems, and 2
+// when some files cannot be read or parsed.
+func runLint(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	disable := flags.String("disable", "", "comma-separated rules not to report")
+	asJSON := flags.Bool("json", false, "print the problems as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: monkey lint [-disable rules] [-json] file...")
+		return 2
+	}
+
+	disabled := make(map[string]bool)
+	if *disable != "" {
+		for _, rule := range strings.Split(*disable, ",") {
+			rule = strings.TrimSpace(rule)
+			if !knownRule(rule) {
+				fmt.Fprintf(stderr, "unknown rule %q\n", rule)
+				return 2
+			}
+			disabled[rule] = true
+		}
+	}
+
+	status := 0
+	all := []diag.Diagnostic{}
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			status = 2
+			continue
+		}
+		problems, err := lint.Source(path, src)
+		var list diag.List
+		if *asJSON && errors.As(err, &list) {
+			all = append(all, list...)
+		}
+		if err != nil {
+			if !*asJSON {
+				printError(stderr, src, err)
+			}
+			status = 2
+			continue
+		}
+
+		var kept []diag.Diagnostic
+		for _, p := range problems {
+			if !disabled[p.Code] {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) > 0 && status == 0 {
+			status = 1
+		}
+		if *asJSON {
+			all = append(all, kept...)
+		} else {
+			printDiagnostics(stdout, src, kept)
+		}
+	}
+	if *asJSON {
+		diag.FprintJSON(stdout, all)
+	}
+	return status
+}
+
+func knownRule(name string) bool {
+	for _, rule := range lint.Rules {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
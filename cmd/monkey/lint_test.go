@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunLint(t *testing.T) {
+	dir := t.TempDir()
+	clean := filepath.Join(dir, "clean.mk")
+	sloppy := filepath.Join(dir, "sloppy.mk")
+	broken := filepath.Join(dir, "broken.mk")
+	os.WriteFile(clean, []byte("let f = fn(x) { x };\nf(1);\n"), 0644)
+	os.WriteFile(sloppy, []byte("let f = fn(x) { 1 };\nnope(1 == 1);\n"), 0644)
+	os.WriteFile(broken, []byte("let = 1\n"), 0644)
+
+	tests := []struct {
+		args     []string
+		status   int
+		expected string
+	}{
+		{[]string{clean}, 0, ""},
+		{
+			[]string{clean, sloppy},
+			1,
+			sloppy + ":1:12: warning: parameter x is never used (unused-parameter)\n" +
+				" 1 | let f = fn(x) { 1 };\n" +
+				"   |            ^\n" +
+				"   = help: rename it _x to show it is meant to be unused\n" +
+				sloppy + ":2:1: warning: nope is not defined (undefined-function)\n" +
+				" 2 | nope(1 == 1);\n" +
+				"   | ^~~~\n" +
+				sloppy + ":2:6: warning: comparison is always true (constant-comparison)\n" +
+				" 2 | nope(1 == 1);\n" +
+				"   |      ^\n",
+		},
+		{
+			[]string{"-disable", "unused-parameter,constant-comparison", sloppy},
+			1,
+			sloppy + ":2:1: warning: nope is not defined (undefined-function)\n" +
+				" 2 | nope(1 == 1);\n" +
+				"   | ^~~~\n",
+		},
+		{
+			[]string{"-json", "-disable", "unused-parameter,constant-comparison", sloppy, broken},
+			2,
+			`[{"file":"` + sloppy + `","severity":"warning","code":"undefined-function","message":"nope is not defined",` +
+				`"start":{"line":2,"column":1},"end":{"line":2,"column":5}},` +
+				`{"file":"` + broken + `","severity":"error","message":"expected next token to be IDENT, got = instead",` +
+				`"start":{"line":1,"column":5},"end":{"line":1,"column":6}}]` + "\n",
+		},
+		{[]string{"-json", clean}, 0, "[]\n"},
+		{[]string{"-disable", "no-such-rule", sloppy}, 2, ""},
+		{[]string{broken}, 2, ""},
+		{nil, 2, ""},
+	}
+
+	for i, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		status := runLint(tt.args, &stdout, &stderr)
+		if status != tt.status {
+			t.Errorf("tests[%d] wrong status, expected=%d, got=%d (%s)", i, tt.status, status, stderr.String())
+		}
+		if stdout.String() != tt.expected {
+			t.Errorf("tests[%d] wrong output, expected=%q, got=%q", i, tt.expected, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"monkey"
+	"monkey/diag"
+	"monkey/lsp"
+	"monkey/object"
+	"monkey/repl"
+	"os"
+	"os/user"
+	"strings"
+)
+
+var (
+	allow   = flag.String("allow", "", "comma-separated capabilities granted to scripts (default all)")
+	session = flag.String("session", "", "REPL session saved with :save to restore at start")
+	asJSON  = flag.Bool("json", false, "print a script's errors to stderr as JSON")
+)
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		os.Exit(runFmt(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lint" {
+		os.Exit(runLint(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "lsp" {
+		if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	flag.Parse()
+	if flag.NArg() > 0 {
+		os.Exit(run(flag.Arg(0), flag.Args()[1:], os.Stderr))
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Hello %s! This is the Monkey programming language!\n", usr.Username)
+	os.Exit(repl.StartSession(os.Stdin, os.Stdout, *session))
+}
+
+// run runs the script at path, reporting its errors to stderr with the lines
+// they are on, or with -json as a JSON array, as lint does.
+func run(path string, args []string, stderr io.Writer) int {
+	opts := []monkey.Option{monkey.WithArgs(args...)}
+	if *allow != "" {
+		caps, err := parseCapabilities(*allow)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		opts = append(opts, monkey.WithCapabilities(caps...))
+	}
+
+	interpreter := monkey.NewInterpreter(opts...)
+	_, err := interpreter.EvalFile(context.Background(), path)
+	if err == nil {
+		return 0
+	}
+
+	var diagnostics []diag.Diagnostic
+	var parseErr *monkey.ParseError
+	var runtimeErr *monkey.RuntimeError
+	switch {
+	case errors.As(err, &parseErr):
+		diagnostics = parseErr.Diagnostics
+	case errors.As(err, &runtimeErr):
+		if code, ok := runtimeErr.ExitCode(); ok {
+			return code
+		}
+		diagnostics = []diag.Diagnostic{runtimeErr.Diagnostic()}
+	default:
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if *asJSON {
+		diag.FprintJSON(stderr, diagnostics)
+		return 1
+	}
+	src, _ := os.ReadFile(path)
+	for _, d := range diagnostics {
+		if d.Pos().Filename == path {
+			printDiagnostics(stderr, src, []diag.Diagnostic{d})
+		} else {
+			printDiagnostics(stderr, nil, []diag.Diagnostic{d})
+		}
+	}
+	return 1
+}
+
+func parseCapabilities(list string) ([]object.Capability, error) {
+	var caps []object.Capability
+	for _, name := range strings.Split(list, ",") {
+		if name == "none" {
+			continue
+		}
+		c, ok := object.ParseCapability(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown capability %q", name)
+		}
+		caps = append(caps, c)
+	}
+	return caps, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunJSON(t *testing.T) {
+	dir := t.TempDir()
+	failing := filepath.Join(dir, "failing.mk")
+	broken := filepath.Join(dir, "broken.mk")
+	os.WriteFile(failing, []byte("let x = 1;\nx + true;\n"), 0644)
+	os.WriteFile(broken, []byte("let = 1\n"), 0644)
+
+	*asJSON = true
+	defer func() { *asJSON = false }()
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{
+			failing,
+			`[{"file":"` + failing + `","severity":"error","code":"TypeError","message":"type mismatch: INTEGER + BOOLEAN",` +
+				`"start":{"line":2,"column":1},"end":{"line":2,"column":1},"notes":["at \u003cmain\u003e (` + failing + `:2:1)"]}]` + "\n",
+		},
+		{
+			broken,
+			`[{"file":"` + broken + `","severity":"error","message":"expected next token to be IDENT, got = instead",` +
+				`"start":{"line":1,"column":5},"end":{"line":1,"column":6}}]` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		var stderr bytes.Buffer
+		if status := run(tt.path, nil, &stderr); status != 1 {
+			t.Errorf("%s: wrong status, expected=1, got=%d", tt.path, status)
+		}
+		if stderr.String() != tt.expected {
+			t.Errorf("%s: wrong output, expected=\n%s\ngot=\n%s", tt.path, tt.expected, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"monkey/diag"
+	"os"
+)
+
+// printDiagnostics shows each of diagnostics with its line of src, colored
+// when w is a terminal and NO_COLOR is not set.
+func printDiagnostics(w io.Writer, src []byte, diagnostics []diag.Diagnostic) {
+	for _, d := range diagnostics {
+		diag.Fprint(w, src, d, useColor(w))
+	}
+}
+
+// printError shows err with its line of src if it is a diag.List, and as it
+// is otherwise.
+func printError(w io.Writer, src []byte, err error) {
+	var list diag.List
+	if errors.As(err, &list) {
+		printDiagnostics(w, src, list)
+		return
+	}
+	fmt.Fprintln(w, err)
+}
+
+func useColor(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,344 @@
+package monkey
+
+import (
+	"fmt"
+	"math"
+	"monkey/evaluator"
+	"monkey/object"
+	"reflect"
+)
+
+var objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+
+// visit identifies a pointer, map or slice being converted, so that one
+// found again inside itself is reported rather than followed for ever.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// ToObject converts a Go value into a Monkey object. Booleans, integers,
+// strings, slices, arrays, maps and structs convert to their Monkey
+// counterparts, nil pointers, slices, maps and interfaces to null, and
+// functions to builtins as by BindFunc. Struct fields are keyed by their name
+// or by a `monkey:"name"` tag; a tag of "-" leaves the field out.
+//
+// Monkey has no floating point type, so floats convert only when they hold a
+// whole number. A value that contains itself is an error.
+func ToObject(v interface{}) (object.Object, error) {
+	if v == nil {
+		return evaluator.NULL, nil
+	}
+	return toObject(reflect.ValueOf(v), nil)
+}
+
+// toObject converts v, inside being the pointers, maps and slices it was
+// reached through.
+func toObject(v reflect.Value, inside map[visit]bool) (object.Object, error) {
+	if !v.IsValid() {
+		return evaluator.NULL, nil
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return evaluator.NULL, nil
+	}
+	if v.Type().Implements(objectType) {
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			break
+		}
+		key := visit{ptr: v.Pointer(), typ: v.Type()}
+		if v.Kind() == reflect.Slice {
+			key.len = v.Len()
+		}
+		if inside[key] {
+			return nil, fmt.Errorf("cannot convert %s that contains itself", v.Type())
+		}
+		if inside == nil {
+			inside = make(map[visit]bool)
+		}
+		inside[key] = true
+		defer delete(inside, key)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows INTEGER", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return nil, fmt.Errorf("%v has no INTEGER representation", f)
+		}
+		return &object.Integer{Value: int64(f)}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			element, err := toObject(v.Index(i), inside)
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %s", i, err)
+			}
+			elements[i] = element
+		}
+		return object.NewArray(elements), nil
+	case reflect.Map:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		hash := &object.Hash{}
+		iter := v.MapRange()
+		for iter.Next() {
+			var err error
+			if hash, err = setPair(hash, iter.Key(), iter.Value(), inside); err != nil {
+				return nil, err
+			}
+		}
+		return hash, nil
+	case reflect.Struct:
+		hash := &object.Hash{}
+		for i := 0; i < v.NumField(); i++ {
+			name, ok := fieldName(v.Type().Field(i))
+			if !ok {
+				continue
+			}
+			var err error
+			if hash, err = setPair(hash, reflect.ValueOf(name), v.Field(i), inside); err != nil {
+				return nil, err
+			}
+		}
+		return hash, nil
+	case reflect.Ptr, reflect.Interface:
+		return toObject(v.Elem(), inside)
+	case reflect.Func:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return bindFunc("function", v)
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a Monkey object", v.Type())
+	}
+}
+
+func setPair(hash *object.Hash, k, v reflect.Value, inside map[visit]bool) (*object.Hash, error) {
+	key, err := toObject(k, inside)
+	if err != nil {
+		return nil, err
+	}
+	hashable, ok := key.(object.Hashable)
+	if !ok {
+		return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+	}
+	value, err := toObject(v, inside)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %s", key.Inspect(), err)
+	}
+	return hash.Set(hashable.HashKey(), object.HashPair{Key: key, Value: value}), nil
+}
+
+func fieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	switch tag := field.Tag.Get("monkey"); tag {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	default:
+		return tag, true
+	}
+}
+
+// FromObject stores obj in the value pointed to by target, converting it with
+// the same rules as ToObject in reverse. An empty interface receives int64,
+// string, bool, nil, []interface{} or map[string]interface{} values; a hash
+// with keys other than strings becomes a map[interface{}]interface{}. A nil
+// obj is treated as null.
+func FromObject(obj object.Object, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("target must be a non-nil pointer, got %T", target)
+	}
+	return fromObject(obj, v.Elem())
+}
+
+func fromObject(obj object.Object, v reflect.Value) error {
+	if obj == nil {
+		obj = evaluator.NULL
+	}
+	emptyInterface := v.Kind() == reflect.Interface && v.NumMethod() == 0
+	if !emptyInterface && reflect.TypeOf(obj).AssignableTo(v.Type()) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	if obj == evaluator.NULL {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			v.SetBool(b.Value)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if integer, ok := obj.(*object.Integer); ok {
+			if v.OverflowInt(integer.Value) {
+				return fmt.Errorf("%d overflows %s", integer.Value, v.Type())
+			}
+			v.SetInt(integer.Value)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if integer, ok := obj.(*object.Integer); ok {
+			if integer.Value < 0 || v.OverflowUint(uint64(integer.Value)) {
+				return fmt.Errorf("%d overflows %s", integer.Value, v.Type())
+			}
+			v.SetUint(uint64(integer.Value))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if integer, ok := obj.(*object.Integer); ok {
+			v.SetFloat(float64(integer.Value))
+			return nil
+		}
+	case reflect.String:
+		if str, ok := obj.(*object.String); ok {
+			v.SetString(str.Value)
+			return nil
+		}
+	case reflect.Slice:
+		if array, ok := obj.(*object.Array); ok {
+			slice := reflect.MakeSlice(v.Type(), array.Len(), array.Len())
+			for i, element := range array.Elements() {
+				if err := fromObject(element, slice.Index(i)); err != nil {
+					return fmt.Errorf("index %d: %s", i, err)
+				}
+			}
+			v.Set(slice)
+			return nil
+		}
+	case reflect.Array:
+		if array, ok := obj.(*object.Array); ok {
+			if array.Len() != v.Len() {
+				return fmt.Errorf("cannot convert ARRAY of length %d to %s", array.Len(), v.Type())
+			}
+			for i, element := range array.Elements() {
+				if err := fromObject(element, v.Index(i)); err != nil {
+					return fmt.Errorf("index %d: %s", i, err)
+				}
+			}
+			return nil
+		}
+	case reflect.Map:
+		if hash, ok := obj.(*object.Hash); ok {
+			m := reflect.MakeMapWithSize(v.Type(), hash.Len())
+			for _, pair := range hash.Pairs() {
+				key := reflect.New(v.Type().Key()).Elem()
+				if err := fromObject(pair.Key, key); err != nil {
+					return fmt.Errorf("key %s: %s", pair.Key.Inspect(), err)
+				}
+				value := reflect.New(v.Type().Elem()).Elem()
+				if err := fromObject(pair.Value, value); err != nil {
+					return fmt.Errorf("key %s: %s", pair.Key.Inspect(), err)
+				}
+				m.SetMapIndex(key, value)
+			}
+			v.Set(m)
+			return nil
+		}
+	case reflect.Struct:
+		if hash, ok := obj.(*object.Hash); ok {
+			for i := 0; i < v.NumField(); i++ {
+				name, ok := fieldName(v.Type().Field(i))
+				if !ok {
+					continue
+				}
+				pair, ok := hash.Get((&object.String{Value: name}).HashKey())
+				if !ok {
+					continue
+				}
+				if err := fromObject(pair.Value, v.Field(i)); err != nil {
+					return fmt.Errorf("field %s: %s", name, err)
+				}
+			}
+			return nil
+		}
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := fromObject(obj, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			native, err := nativeValue(obj)
+			if err != nil {
+				return err
+			}
+			if native == nil {
+				v.Set(reflect.Zero(v.Type()))
+			} else {
+				v.Set(reflect.ValueOf(native))
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), v.Type())
+}
+
+func nativeValue(obj object.Object) (interface{}, error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.Null:
+		return nil, nil
+	case *object.Array:
+		var values []interface{}
+		if err := fromObject(obj, reflect.ValueOf(&values).Elem()); err != nil {
+			return nil, err
+		}
+		return values, nil
+	case *object.Hash:
+		for _, pair := range obj.Pairs() {
+			if pair.Key.Type() != object.STRING_OBJ {
+				var values map[interface{}]interface{}
+				err := fromObject(obj, reflect.ValueOf(&values).Elem())
+				return values, err
+			}
+		}
+		var values map[string]interface{}
+		err := fromObject(obj, reflect.ValueOf(&values).Elem())
+		return values, err
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a Go value", obj.Type())
+	}
+}
@@ -0,0 +1,208 @@
+package monkey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"monkey/object"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type account struct {
+	Owner   string `monkey:"owner"`
+	Balance int64  `monkey:"balance"`
+	Tags    []string
+	Secret  string `monkey:"-"`
+	hidden  int
+}
+
+type node struct {
+	Value int
+	Next  *node
+}
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{3.0, "3"},
+		{"monkey", "monkey"},
+		{true, "true"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{map[string]int{"a": 1}, "{a: 1}"},
+		{&account{Owner: "ana", Tags: []string{"x"}}, ""},
+		{[]int(nil), "null"},
+		{(*account)(nil), "null"},
+		{&object.Integer{Value: 5}, "5"},
+		{[]object.Object{nil, &object.Integer{Value: 5}}, "[null, 5]"},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Fatalf("ToObject(%#v) returned error: %s", tt.input, err)
+		}
+		if tt.expected != "" && obj.Inspect() != tt.expected {
+			t.Fatalf("ToObject(%#v) wrong, expected=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+
+	obj, _ := ToObject(account{Owner: "ana", Balance: 10, Secret: "s"})
+	hash := obj.(*object.Hash)
+	if hash.Len() != 3 {
+		t.Fatalf("struct should have 3 visible fields, got=%d", hash.Len())
+	}
+	owner, _ := hash.Get((&object.String{Value: "owner"}).HashKey())
+	if owner.Value.Inspect() != "ana" {
+		t.Fatalf("wrong owner, got=%s", owner.Value.Inspect())
+	}
+
+	for _, bad := range []interface{}{1.5, make(chan int), uint64(1 << 63)} {
+		if _, err := ToObject(bad); err == nil {
+			t.Fatalf("ToObject(%#v) should fail", bad)
+		}
+	}
+}
+
+func TestToObjectCycles(t *testing.T) {
+	list := &node{Value: 1}
+	list.Next = &node{Value: 2, Next: list}
+	slice := []interface{}{1, nil}
+	slice[1] = slice
+	m := map[string]interface{}{}
+	m["self"] = m
+
+	for _, cyclic := range []interface{}{list, slice, m} {
+		_, err := ToObject(cyclic)
+		if err == nil || !strings.Contains(err.Error(), "contains itself") {
+			t.Fatalf("expected a cycle error for %T, got=%v", cyclic, err)
+		}
+	}
+
+	shared := &node{Value: 1}
+	obj, err := ToObject([]*node{shared, shared})
+	if err != nil {
+		t.Fatalf("unexpected error for a shared value: %s", err)
+	}
+	if obj.Inspect() != "[{Next: null, Value: 1}, {Next: null, Value: 1}]" {
+		t.Fatalf("wrong result, got=%s", obj.Inspect())
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	interpreter := NewInterpreter()
+	obj, err := interpreter.Eval(context.Background(), `{"owner": "ana", "balance": 10, "Tags": ["a", "b"], "extra": true}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var acct account
+	if err := FromObject(obj, &acct); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := account{Owner: "ana", Balance: 10, Tags: []string{"a", "b"}}
+	if !reflect.DeepEqual(acct, expected) {
+		t.Fatalf("wrong struct, expected=%+v, got=%+v", expected, acct)
+	}
+
+	var native interface{}
+	if err := FromObject(obj, &native); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	m, ok := native.(map[string]interface{})
+	if !ok || m["balance"] != int64(10) || m["extra"] != true {
+		t.Fatalf("wrong native value, got=%#v", native)
+	}
+
+	var small int8
+	if err := FromObject(&object.Integer{Value: 300}, &small); err == nil {
+		t.Fatalf("expected overflow error")
+	}
+	var n int
+	if err := FromObject(&object.String{Value: "1"}, &n); err == nil || err.Error() != "cannot convert STRING to int" {
+		t.Fatalf("expected conversion error, got=%v", err)
+	}
+	if err := FromObject(obj, acct); err == nil {
+		t.Fatalf("expected error for non-pointer target")
+	}
+
+	ptr := &acct
+	if err := FromObject(nil, &ptr); err != nil || ptr != nil {
+		t.Fatalf("expected nil to convert like null, got=%v (%v)", ptr, err)
+	}
+	if err := FromObject(nil, &n); err == nil || err.Error() != "cannot convert NULL to int" {
+		t.Fatalf("expected conversion error, got=%v", err)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	interpreter := NewInterpreter()
+	must := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(interpreter.RegisterFunc("greet", func(name string, times int) string {
+		return strings.Repeat("hi "+name+" ", times)
+	}))
+	must(interpreter.RegisterFunc("sum", func(xs ...int) int {
+		total := 0
+		for _, x := range xs {
+			total += x
+		}
+		return total
+	}))
+	must(interpreter.RegisterFunc("divide", func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return a / b, nil
+	}))
+	must(interpreter.RegisterFunc("lookup", func(key string) (int, error) {
+		return 0, &object.Error{Kind: object.IndexError, Message: "no such key: " + key}
+	}))
+	must(interpreter.RegisterFunc("boom", func() { panic("bad") }))
+	must(interpreter.RegisterFunc("balance", func(a account) int64 { return a.Balance }))
+	must(interpreter.RegisterFunc("nothing", func() object.Object { return nil }))
+	interpreter.RegisterBuiltin("nilret", func(env *object.Environment, args ...object.Object) object.Object { return nil })
+
+	if err := interpreter.RegisterFunc("pair", func() (int, int) { return 1, 2 }); err == nil {
+		t.Fatalf("expected an error binding a function with two non-error results")
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`greet("ana", 2)`, "hi ana hi ana "},
+		{`sum()`, "0"},
+		{`sum(1, 2, 3)`, "6"},
+		{`divide(6, 3)`, "2"},
+		{`divide(1, 0)`, "division by zero"},
+		{`divide(1)`, "`divide` takes 2 argument(s), got 1"},
+		{`greet(1, 2)`, "argument 1 to `greet`: cannot convert INTEGER to string"},
+		{`boom()`, "`boom` panicked: bad"},
+		{`try { lookup("a") } catch (e) { e["kind"] + ": " + e["message"] }`, "IndexError: no such key: a"},
+		{`balance({"balance": 7})`, "7"},
+		{`nothing()`, "null"},
+		{`[nilret()]`, "[null]"},
+	}
+
+	for _, tt := range tests {
+		result, err := interpreter.Eval(context.Background(), tt.input)
+		got := fmt.Sprint(err)
+		if err == nil {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Fatalf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package diag describes problems found in Monkey source, by the parser,
+// the evaluator or the linter, and shows them to people, with the line they
+// are on, or to editors as JSON.
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"monkey/token"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Info
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Info:
+		return "info"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Span is the source from Start up to but not including End. An End that is
+// not after Start marks a single place, such as where something is missing.
+type Span struct {
+	Start token.Position
+	End   token.Position
+}
+
+// TokenSpan returns the span of tok in the source, quotes included.
+func TokenSpan(tok token.Token) Span {
+	text := tok.Literal
+	switch tok.Type {
+	case token.STRING, token.TEMPLATE:
+		text = `"` + text + `"`
+	case token.EOF:
+		text = ""
+	}
+	return Span{Start: tok.Pos, End: tok.Pos.Advance(text)}
+}
+
+// Fix suggests replacing the source in Span with Replacement; an empty span
+// inserts it.
+type Fix struct {
+	Message     string
+	Span        Span
+	Replacement string
+}
+
+type Diagnostic struct {
+	Severity Severity
+	Span     Span
+	Message  string
+	// Code names the kind of problem, such as the lint rule that found it.
+	Code  string
+	Notes []string
+	Fix   *Fix
+}
+
+func (d Diagnostic) Pos() token.Position {
+	return d.Span.Start
+}
+
+// String gives the position, message and code on one line.
+func (d Diagnostic) String() string {
+	s := d.Span.Start.String() + ": " + d.Message
+	if d.Code != "" {
+		s += " (" + d.Code + ")"
+	}
+	return s
+}
+
+func (d Diagnostic) Error() string {
+	return d.String()
+}
+
+// List is a Diagnostic for each of several problems, usable as an error.
+type List []Diagnostic
+
+func (l List) Error() string {
+	return strings.Join(l.Strings(), "\n")
+}
+
+// Strings returns the diagnostics one line each, as String does.
+func (l List) Strings() []string {
+	lines := make([]string, len(l))
+	for i, d := range l {
+		lines[i] = d.String()
+	}
+	return lines
+}
+
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type jsonFix struct {
+	Message     string       `json:"message"`
+	Start       jsonPosition `json:"start"`
+	End         jsonPosition `json:"end"`
+	Replacement string       `json:"replacement"`
+}
+
+type jsonDiagnostic struct {
+	File     string       `json:"file,omitempty"`
+	Severity string       `json:"severity"`
+	Code     string       `json:"code,omitempty"`
+	Message  string       `json:"message"`
+	Start    jsonPosition `json:"start"`
+	End      jsonPosition `json:"end"`
+	Notes    []string     `json:"notes,omitempty"`
+	Fix      *jsonFix     `json:"fix,omitempty"`
+}
+
+// MarshalJSON gives d as an object with file, severity, code, message,
+// start and end positions of line and column, notes and fix.
+func (d Diagnostic) MarshalJSON() ([]byte, error) {
+	start, end := d.Span.Start, d.Span.End
+	if !end.IsValid() {
+		end = start
+	}
+	j := jsonDiagnostic{
+		File:     start.Filename,
+		Severity: d.Severity.String(),
+		Code:     d.Code,
+		Message:  d.Message,
+		Start:    jsonPosition{start.Line, start.Column},
+		End:      jsonPosition{end.Line, end.Column},
+		Notes:    d.Notes,
+	}
+	if d.Fix != nil {
+		j.Fix = &jsonFix{
+			Message:     d.Fix.Message,
+			Start:       jsonPosition{d.Fix.Span.Start.Line, d.Fix.Span.Start.Column},
+			End:         jsonPosition{d.Fix.Span.End.Line, d.Fix.Span.End.Column},
+			Replacement: d.Fix.Replacement,
+		}
+	}
+	return json.Marshal(j)
+}
@@ -0,0 +1,124 @@
+package diag
+
+import (
+	"bytes"
+	"monkey/token"
+	"testing"
+)
+
+func pos(line, column int) token.Position {
+	return token.Position{Filename: "x.mk", Line: line, Column: column}
+}
+
+func TestFprint(t *testing.T) {
+	src := []byte("let a = 1;\n\tlet é = f(1, 2;\r\nlet b = 2;\n")
+	tests := []struct {
+		diagnostic Diagnostic
+		src        []byte
+		expected   string
+	}{
+		{
+			Diagnostic{Severity: Error, Span: Span{Start: pos(1, 5), End: pos(1, 6)}, Message: "bad"},
+			src,
+			"x.mk:1:5: error: bad\n 1 | let a = 1;\n   |     ^\n",
+		},
+		{
+			Diagnostic{
+				Severity: Warning,
+				Span:     Span{Start: pos(2, 13), End: pos(2, 17)},
+				Message:  "unused",
+				Code:     "unused-binding",
+				Notes:    []string{"first", "second"},
+				Fix:      &Fix{Message: "insert ')'", Span: Span{Start: pos(2, 17), End: pos(2, 17)}, Replacement: ")"},
+			},
+			src,
+			"x.mk:2:13: warning: unused (unused-binding)\n 2 | \tlet é = f(1, 2;\n   | \t          ^~~~\n   = note: first\n   = note: second\n   = help: insert ')'\n",
+		},
+		{
+			Diagnostic{Severity: Info, Span: Span{Start: pos(3, 1), End: pos(4, 1)}, Message: "spans lines"},
+			src,
+			"x.mk:3:1: info: spans lines\n 3 | let b = 2;\n   | ^~~~~~~~~~\n",
+		},
+		{
+			Diagnostic{Severity: Error, Span: Span{Start: pos(1, 11)}, Message: "at the end"},
+			src,
+			"x.mk:1:11: error: at the end\n 1 | let a = 1;\n   |           ^\n",
+		},
+		{
+			Diagnostic{Severity: Error, Span: Span{Start: pos(1, 1)}, Message: "no source", Notes: []string{"a note"}},
+			nil,
+			"x.mk:1:1: error: no source\n = note: a note\n",
+		},
+		{
+			Diagnostic{Severity: Error, Message: "nowhere"},
+			src,
+			"error: nowhere\n",
+		},
+	}
+
+	for i, tt := range tests {
+		var out bytes.Buffer
+		if err := Fprint(&out, tt.src, tt.diagnostic, false); err != nil {
+			t.Fatalf("tests[%d] unexpected error: %s", i, err)
+		}
+		if out.String() != tt.expected {
+			t.Errorf("tests[%d] wrong output, expected=%q, got=%q", i, tt.expected, out.String())
+		}
+	}
+}
+
+func TestFprintColor(t *testing.T) {
+	var out bytes.Buffer
+	d := Diagnostic{Severity: Error, Span: Span{Start: pos(1, 1), End: pos(1, 4)}, Message: "bad"}
+	Fprint(&out, []byte("let"), d, true)
+	expected := "\x1b[1mx.mk:1:1:\x1b[0m \x1b[1;31merror:\x1b[0m \x1b[1mbad\x1b[0m\n 1 | let\n   | \x1b[1;31m^~~\x1b[0m\n"
+	if out.String() != expected {
+		t.Errorf("wrong output, expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestFprintJSON(t *testing.T) {
+	diagnostics := []Diagnostic{
+		{
+			Severity: Error,
+			Span:     Span{Start: pos(1, 15)},
+			Message:  "expected ')'",
+			Fix:      &Fix{Message: "insert ')'", Span: Span{Start: pos(1, 15), End: pos(1, 15)}, Replacement: ")"},
+		},
+		{Severity: Warning, Span: Span{Start: pos(2, 1), End: pos(2, 5)}, Message: "unused", Code: "unused-binding", Notes: []string{"note"}},
+	}
+	expected := `[{"file":"x.mk","severity":"error","message":"expected ')'","start":{"line":1,"column":15},"end":{"line":1,"column":15},` +
+		`"fix":{"message":"insert ')'","start":{"line":1,"column":15},"end":{"line":1,"column":15},"replacement":")"}},` +
+		`{"file":"x.mk","severity":"warning","code":"unused-binding","message":"unused","start":{"line":2,"column":1},"end":{"line":2,"column":5},"notes":["note"]}]` + "\n"
+
+	var out bytes.Buffer
+	if err := FprintJSON(&out, diagnostics); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out.String() != expected {
+		t.Errorf("wrong output, expected=%s, got=%s", expected, out.String())
+	}
+
+	out.Reset()
+	FprintJSON(&out, nil)
+	if out.String() != "[]\n" {
+		t.Errorf("expected an empty array, got=%s", out.String())
+	}
+}
+
+func TestTokenSpan(t *testing.T) {
+	tests := []struct {
+		tok      token.Token
+		expected int
+	}{
+		{token.Token{Type: token.IDENT, Literal: "name", Pos: pos(1, 1)}, 5},
+		{token.Token{Type: token.STRING, Literal: "hi", Pos: pos(1, 1)}, 5},
+		{token.Token{Type: token.EOF, Literal: "", Pos: pos(1, 1)}, 1},
+	}
+
+	for i, tt := range tests {
+		if end := TokenSpan(tt.tok).End; end.Line != 1 || end.Column != tt.expected {
+			t.Errorf("tests[%d] wrong end, expected=1:%d, got=%s", i, tt.expected, end)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+)
+
+var severityColors = map[Severity]string{
+	Error:   "\x1b[1;31m",
+	Warning: "\x1b[1;35m",
+	Info:    "\x1b[1;36m",
+}
+
+// Fprint writes d for a person to read: the position, severity and message,
+// then the line of src it is on with the span underlined, then its notes
+// and suggested fix. src may be nil, leaving out the line. With color set
+// the output is colored for a terminal.
+func Fprint(w io.Writer, src []byte, d Diagnostic, color bool) error {
+	paint := func(code, s string) string {
+		if !color {
+			return s
+		}
+		return code + s + colorReset
+	}
+
+	var out bytes.Buffer
+	if d.Span.Start.IsValid() {
+		out.WriteString(paint(colorBold, d.Span.Start.String()+":") + " ")
+	}
+	out.WriteString(paint(severityColors[d.Severity], d.Severity.String()+":") + " ")
+	message := d.Message
+	if d.Code != "" {
+		message += " (" + d.Code + ")"
+	}
+	out.WriteString(paint(colorBold, message) + "\n")
+
+	margin := ""
+	if line, ok := sourceLine(src, d.Span.Start.Line); ok {
+		number := strconv.Itoa(d.Span.Start.Line)
+		margin = strings.Repeat(" ", len(number)+1)
+		fmt.Fprintf(&out, " %s | %s\n", number, line)
+		underline := padding(line, d.Span.Start.Column) + "^" + strings.Repeat("~", spanWidth(line, d.Span)-1)
+		fmt.Fprintf(&out, "%s | %s\n", margin, paint(severityColors[d.Severity], underline))
+	}
+	for _, note := range d.Notes {
+		fmt.Fprintf(&out, "%s = %s %s\n", margin, paint(colorBold, "note:"), note)
+	}
+	if d.Fix != nil {
+		fmt.Fprintf(&out, "%s = %s %s\n", margin, paint(colorBold, "help:"), d.Fix.Message)
+	}
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// FprintJSON writes diagnostics as a JSON array on one line, for editors and
+// other tools.
+func FprintJSON(w io.Writer, diagnostics []Diagnostic) error {
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	data, err := json.Marshal(diagnostics)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// sourceLine returns line n of src, counting from 1.
+func sourceLine(src []byte, n int) (string, bool) {
+	if src == nil || n < 1 {
+		return "", false
+	}
+	lines := strings.Split(string(src), "\n")
+	if n > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[n-1], "\r"), true
+}
+
+// padding is the space before column of line, keeping its tabs so the
+// underline lines up however wide the terminal shows them.
+func padding(line string, column int) string {
+	var pad strings.Builder
+	for i := 0; i < column-1 && i < len(line); i++ {
+		switch {
+		case line[i] == '\t':
+			pad.WriteByte('\t')
+		case utf8.RuneStart(line[i]):
+			pad.WriteByte(' ')
+		}
+	}
+	return pad.String()
+}
+
+// spanWidth is how many characters of line to underline for span: as far as
+// its end when that is on the same line, to the end of line when it is on a
+// later one, and otherwise one.
+func spanWidth(line string, span Span) int {
+	start, end := span.Start.Column-1, len(line)
+	if span.End.Line == span.Start.Line {
+		end = span.End.Column - 1
+	} else if span.End.Line < span.Start.Line {
+		return 1
+	}
+	if start < 0 || start >= len(line) || end > len(line) || end <= start {
+		return 1
+	}
+	return utf8.RuneCountInString(line[start:end])
+}
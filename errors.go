@@ -0,0 +1,56 @@
+package monkey
+
+import (
+	"monkey/diag"
+	"monkey/evaluator"
+	"monkey/object"
+	"strings"
+)
+
+// ParseError is returned when the source could not be parsed; Errors holds
+// every message the parser reported, and Diagnostics the same in full.
+type ParseError struct {
+	Errors      []string
+	Diagnostics []diag.Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	return "parse error: " + strings.Join(e.Errors, "; ")
+}
+
+// RuntimeError is returned when evaluation produced a Monkey error value. It
+// unwraps to the underlying *object.Error, whose Kind and Details describe
+// the failure.
+type RuntimeError struct {
+	Err *object.Error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Err.Message
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// Kind is the kind of the underlying error, such as object.TypeError.
+func (e *RuntimeError) Kind() string {
+	return e.Err.KindName()
+}
+
+// Diagnostic describes the error at the place it was raised, with the calls
+// that led there as notes.
+func (e *RuntimeError) Diagnostic() diag.Diagnostic {
+	return evaluator.Diagnostic(e.Err)
+}
+
+// ExitCode reports the status code a script passed to exit, if that is what
+// stopped it.
+func (e *RuntimeError) ExitCode() (int, bool) {
+	return e.Err.ExitCode()
+}
+
+// StackTrace describes the calls that led to the error, innermost first.
+func (e *RuntimeError) StackTrace() string {
+	return e.Err.StackTrace()
+}
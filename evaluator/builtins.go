@@ -2,81 +2,492 @@ package evaluator
 
 import (
 	"fmt"
+	"io"
+	"math"
 	"monkey/object"
+	"sort"
+	"strings"
+	"unicode/utf8"
 )
 
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("`len` takes one argument")
+				return newError(object.ArityError, "`len` takes one argument")
 			}
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return newInteger(int64(utf8.RuneCountInString(arg.Value)))
 			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
+				return newInteger(int64(arg.Len()))
 			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
+				return newError(object.TypeError, "argument to `len` not supported, got %s", args[0].Type())
 			}
-			return NULL
 		},
 	},
 	"first": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("`first` takes one argument")
+				return newError(object.ArityError, "`first` takes one argument")
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("`first` takes an array")
+				return newError(object.TypeError, "`first` takes an array")
 			}
 			arr := args[0].(*object.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
+			if arr.Len() > 0 {
+				return arr.At(0)
 			}
 			return NULL
 		},
 	},
 	"last": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("`last` takes one argument")
+				return newError(object.ArityError, "`last` takes one argument")
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("`last` takes an array")
+				return newError(object.TypeError, "`last` takes an array")
 			}
 			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
+			length := arr.Len()
 			if length > 0 {
-				return arr.Elements[length-1]
+				return arr.At(length - 1)
 			}
 			return NULL
 		},
 	},
 	"rest": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("`rest` takes one argument")
+				return newError(object.ArityError, "`rest` takes one argument")
 			}
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("`rest` takes an array")
+				return newError(object.TypeError, "`rest` takes an array")
 			}
 			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
+			length := arr.Len()
 			if length > 0 {
-				newElements := make([]object.Object, length-1, length-1)
-				copy(newElements, arr.Elements[1:length])
-				return &object.Array{Elements: newElements}
+				if err := allocate(env, arraySize(0)); err != nil {
+					return err
+				}
+				return arr.Slice(1, length)
 			}
 			return NULL
 		},
 	},
+	"push": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return arityError("push", 2, len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError(object.TypeError, "argument 1 to `push` must be %s, got %s", object.ARRAY_OBJ, args[0].Type())
+			}
+			if err := allocate(env, arraySize(1)); err != nil {
+				return err
+			}
+			return arr.Push(args[1])
+		},
+	},
+	"set": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 3 {
+				return arityError("set", 3, len(args))
+			}
+			switch collection := args[0].(type) {
+			case *object.Array:
+				index, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError(object.TypeError, "argument 2 to `set` must be %s, got %s", object.INTEGER_OBJ, args[1].Type())
+				}
+				if index.Value < 0 || index.Value >= int64(collection.Len()) {
+					return newError(object.IndexError, "`set` index %d out of range for array of length %d", index.Value, collection.Len())
+				}
+				if err := allocate(env, arraySize(1)); err != nil {
+					return err
+				}
+				return collection.Set(int(index.Value), args[2])
+			case *object.Hash:
+				key, ok := args[1].(object.Hashable)
+				if !ok {
+					return newError(object.TypeError, "unusable as hash key: %s", args[1].Type())
+				}
+				if err := allocate(env, hashSize(1)); err != nil {
+					return err
+				}
+				return collection.Set(key.HashKey(), object.HashPair{Key: args[1], Value: args[2]})
+			default:
+				return newError(object.TypeError, "argument 1 to `set` must be ARRAY or HASH, got %s", args[0].Type())
+			}
+		},
+	},
+	"delete": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return arityError("delete", 2, len(args))
+			}
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError(object.TypeError, "argument 1 to `delete` must be %s, got %s", object.HASH_OBJ, args[0].Type())
+			}
+			key, ok := args[1].(object.Hashable)
+			if !ok {
+				return newError(object.TypeError, "unusable as hash key: %s", args[1].Type())
+			}
+			return hash.Delete(key.HashKey())
+		},
+	},
 	"puts": &object.Builtin{
-		Fn: func(args ...object.Object) object.Object {
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				text, err := inspect(env, arg)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(env.Runtime().Stdout, text)
 			}
 			return NULL
 		},
+		Capability: object.CapStdout,
+	},
+	"print": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			for _, arg := range args {
+				text, err := inspect(env, arg)
+				if err != nil {
+					return err
+				}
+				fmt.Fprint(env.Runtime().Stdout, text)
+			}
+			return NULL
+		},
+		Capability: object.CapStdout,
+	},
+	"gets":        readLineBuiltin("gets"),
+	"read_line":   readLineBuiltin("read_line"),
+	"read_file":   readFileBuiltin,
+	"read_dir":    readDirBuiltin,
+	"write_file":  writeFileBuiltin,
+	"append_file": appendFileBuiltin,
+	"getenv":      getenvBuiltin,
+	"now":         nowBuiltin,
+	"sleep":       sleepBuiltin,
+	"rand":        randBuiltin,
+	"args":        argsBuiltin,
+	"exit":        exitBuiltin,
+	"split": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArgs("split", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			parts := strings.Split(args[0].(*object.String).Value, args[1].(*object.String).Value)
+			return stringArray(env, parts)
+		},
+	},
+	"join": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArgs("join", args, object.ARRAY_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			elements := args[0].(*object.Array).Elements()
+			separator := args[1].(*object.String).Value
+			parts := make([]string, len(elements))
+			size := 0
+			for i, e := range elements {
+				str, ok := e.(*object.String)
+				if !ok {
+					return newError(object.TypeError, "`join` takes an array of strings, got %s", e.Type())
+				}
+				parts[i] = str.Value
+				size += len(str.Value) + len(separator)
+			}
+			if err := allocate(env, stringSize(size)); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.Join(parts, separator)}
+		},
+	},
+	"trim": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) == 2 {
+				if err := checkArgs("trim", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+					return err
+				}
+				return newString(env, strings.Trim(args[0].(*object.String).Value, args[1].(*object.String).Value))
+			}
+			if err := checkArgs("trim", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return newString(env, strings.TrimSpace(args[0].(*object.String).Value))
+		},
+	},
+	"upper": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArgs("upper", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return newString(env, strings.ToUpper(args[0].(*object.String).Value))
+		},
+	},
+	"lower": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArgs("lower", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return newString(env, strings.ToLower(args[0].(*object.String).Value))
+		},
+	},
+	"replace": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArgs("replace", args, object.STRING_OBJ, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			str := args[0].(*object.String).Value
+			old := args[1].(*object.String).Value
+			new := args[2].(*object.String).Value
+			size := len(str) + strings.Count(str, old)*(len(new)-len(old))
+			if err := allocate(env, stringSize(size)); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.ReplaceAll(str, old, new)}
+		},
+	},
+	"contains": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArgs("contains", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return booleanObjectFromBool(strings.Contains(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+	"starts_with": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArgs("starts_with", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return booleanObjectFromBool(strings.HasPrefix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
+	},
+	"ends_with": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArgs("ends_with", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			return booleanObjectFromBool(strings.HasSuffix(args[0].(*object.String).Value, args[1].(*object.String).Value))
+		},
 	},
+	"index_of": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArgs("index_of", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			str := args[0].(*object.String).Value
+			i := strings.Index(str, args[1].(*object.String).Value)
+			if i < 0 {
+				return newInteger(-1)
+			}
+			return newInteger(int64(utf8.RuneCountInString(str[:i])))
+		},
+	},
+	"repeat": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArgs("repeat", args, object.STRING_OBJ, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+			str := args[0].(*object.String).Value
+			count := args[1].(*object.Integer).Value
+			if count < 0 {
+				return newError(object.ValueError, "`repeat` count must not be negative, got %d", count)
+			}
+			if len(str) > 0 && count > math.MaxInt32/int64(len(str)) {
+				return newError(object.ValueError, "`repeat` result too large")
+			}
+			if err := allocate(env, stringSize(len(str)*int(count))); err != nil {
+				return err
+			}
+			return &object.String{Value: strings.Repeat(str, int(count))}
+		},
+	},
+	"chars": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArgs("chars", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			runes := []rune(args[0].(*object.String).Value)
+			chars := make([]string, len(runes))
+			for i, r := range runes {
+				chars[i] = string(r)
+			}
+			return stringArray(env, chars)
+		},
+	},
+	"ord": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArgs("ord", args, object.STRING_OBJ); err != nil {
+				return err
+			}
+			runes := []rune(args[0].(*object.String).Value)
+			if len(runes) != 1 {
+				return newError(object.ValueError, "`ord` takes a single character, got %d", len(runes))
+			}
+			return newInteger(int64(runes[0]))
+		},
+	},
+	"chr": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if err := checkArgs("chr", args, object.INTEGER_OBJ); err != nil {
+				return err
+			}
+			code := args[0].(*object.Integer).Value
+			if code < 0 || code > utf8.MaxRune || !utf8.ValidRune(rune(code)) {
+				return newError(object.ValueError, "`chr` code point out of range: %d", code)
+			}
+			return newString(env, string(rune(code)))
+		},
+	},
+	"format":  formatBuiltin("format"),
+	"sprintf": formatBuiltin("sprintf"),
+	"error": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) == 3 {
+				if err := checkArgs("error", args, object.STRING_OBJ, object.STRING_OBJ, object.HASH_OBJ); err != nil {
+					return err
+				}
+			} else if err := checkArgs("error", args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+				return err
+			}
+			err := &object.Error{
+				Kind:    args[0].(*object.String).Value,
+				Message: args[1].(*object.String).Value,
+			}
+			if len(args) == 3 {
+				details, ok := hashDetails(args[2].(*object.Hash))
+				if !ok {
+					return newError(object.TypeError, "`error` details must have STRING keys")
+				}
+				err.Details = details
+			}
+			return &object.ErrorValue{Err: err}
+		},
+	},
+	"is_error": &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return arityError("is_error", 1, len(args))
+			}
+			return booleanObjectFromBool(args[0].Type() == object.ERROR_VALUE_OBJ)
+		},
+	},
+}
+
+// readLineBuiltin makes a builtin called name that reads a line from stdin
+// without its line ending, or returns null at the end of the input.
+func readLineBuiltin(name string) *object.Builtin {
+	return &object.Builtin{
+		Name:       name,
+		Capability: object.CapStdin,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) != 0 {
+				return newError(object.ArityError, "`%s` takes no arguments", name)
+			}
+			line, err := env.Runtime().Stdin.ReadString('\n')
+			if err == io.EOF && line == "" {
+				return NULL
+			}
+			if err != nil && err != io.EOF {
+				return newError(object.IOError, "`%s` failed: %s", name, err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			return newString(env, strings.TrimSuffix(line, "\r"))
+		},
+	}
+}
+
+// formatBuiltin makes a builtin called name that fills a printf-style format
+// string in with the rest of its arguments.
+func formatBuiltin(name string) *object.Builtin {
+	return &object.Builtin{
+		Name: name,
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError(object.ArityError, "`%s` takes at least one argument", name)
+			}
+			format, ok := args[0].(*object.String)
+			if !ok {
+				return newError(object.TypeError, "argument 1 to `%s` must be %s, got %s", name, object.STRING_OBJ, args[0].Type())
+			}
+			values := make([]interface{}, len(args)-1)
+			for i, arg := range args[1:] {
+				value, err := formatValue(arg, env)
+				if err != nil {
+					return err
+				}
+				values[i] = value
+			}
+			return newString(env, fmt.Sprintf(format.Value, values...))
+		},
+	}
+}
+
+// BuiltinNames returns the names of the builtins every program can call, in
+// sorted order.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	for name, builtin := range builtins {
+		if builtin.Name == "" {
+			builtin.Name = name
+		}
+	}
+}
+
+// formatValue maps an object onto the Go value the format verbs expect,
+// falling back to its Inspect form for anything without a Go equivalent.
+func formatValue(obj object.Object, env *object.Environment) (interface{}, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	default:
+		return inspect(env, obj)
+	}
+}
+
+func checkArgs(name string, args []object.Object, types ...object.ObjectType) *object.Error {
+	if len(args) != len(types) {
+		return arityError(name, len(types), len(args))
+	}
+	for i, t := range types {
+		if args[i].Type() != t {
+			return newError(object.TypeError, "argument %d to `%s` must be %s, got %s", i+1, name, t, args[i].Type())
+		}
+	}
+	return nil
+}
+
+func stringArray(env *object.Environment, values []string) object.Object {
+	size := arraySize(len(values))
+	for _, v := range values {
+		size += stringSize(len(v))
+	}
+	if err := allocate(env, size); err != nil {
+		return err
+	}
+	elements := make([]object.Object, len(values))
+	for i, v := range values {
+		elements[i] = &object.String{Value: v}
+	}
+	return object.NewArray(elements)
 }
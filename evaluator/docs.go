@@ -0,0 +1,51 @@
+package evaluator
+
+// builtinDocs gives a signature and a sentence about each builtin, for tools
+// such as editors to show.
+var builtinDocs = map[string]string{
+	"len":         "len(value) returns the number of characters in a string or elements in an array.",
+	"first":       "first(array) returns the first element of array, or null if it is empty.",
+	"last":        "last(array) returns the last element of array, or null if it is empty.",
+	"rest":        "rest(array) returns a new array of all but the first element of array, or null if it is empty.",
+	"push":        "push(array, value) returns a new array with value added to the end of array.",
+	"set":         "set(collection, key, value) returns a new array or hash with key set to value.",
+	"delete":      "delete(hash, key) returns a new hash without key.",
+	"puts":        "puts(values...) prints each value on a line of its own and returns null.",
+	"print":       "print(values...) prints the values with nothing between or after them and returns null.",
+	"gets":        "gets() reads a line from standard input, without its line ending, or returns null at the end.",
+	"read_line":   "read_line() reads a line from standard input, without its line ending, or returns null at the end.",
+	"read_file":   "read_file(path) returns the contents of the file at path as a string.",
+	"read_dir":    "read_dir(path) returns the names of the entries in the directory at path.",
+	"write_file":  "write_file(path, content) replaces the contents of the file at path with content.",
+	"append_file": "append_file(path, content) adds content to the end of the file at path.",
+	"getenv":      "getenv(name) returns the value of the environment variable name, or null if it is not set.",
+	"now":         "now() returns the current time in milliseconds since the Unix epoch.",
+	"sleep":       "sleep(ms) waits for ms milliseconds and returns null.",
+	"rand":        "rand(n) returns a random integer from 0 up to but not including n.",
+	"args":        "args() returns the arguments the script was run with.",
+	"exit":        "exit(code) stops the program, asking to end the process with the status code.",
+	"split":       "split(s, separator) returns the parts of s between each separator.",
+	"join":        "join(strings, separator) returns the strings joined with separator between them.",
+	"trim":        "trim(s[, cutset]) returns s without leading and trailing white space, or characters in cutset.",
+	"upper":       "upper(s) returns s in upper case.",
+	"lower":       "lower(s) returns s in lower case.",
+	"replace":     "replace(s, old, new) returns s with every old replaced by new.",
+	"contains":    "contains(s, substring) reports whether substring is in s.",
+	"starts_with": "starts_with(s, prefix) reports whether s begins with prefix.",
+	"ends_with":   "ends_with(s, suffix) reports whether s ends with suffix.",
+	"index_of":    "index_of(s, substring) returns the character index of the first substring in s, or -1.",
+	"repeat":      "repeat(s, count) returns count copies of s joined together.",
+	"chars":       "chars(s) returns the characters of s as an array of strings.",
+	"ord":         "ord(c) returns the code point of the single character c.",
+	"chr":         "chr(code) returns the character with code point code.",
+	"format":      "format(template, values...) formats the values according to template, as Go's fmt.Sprintf does.",
+	"sprintf":     "sprintf(template, values...) formats the values according to template, as Go's fmt.Sprintf does.",
+	"error":       "error(kind, message[, details]) returns an error value of kind, which can be thrown or returned.",
+	"is_error":    "is_error(value) reports whether value is an error value.",
+}
+
+// BuiltinDoc returns the documentation of the builtin name.
+func BuiltinDoc(name string) (string, bool) {
+	doc, ok := builtinDocs[name]
+	return doc, ok
+}
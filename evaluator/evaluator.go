@@ -1,9 +1,14 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"monkey/ast"
+	"monkey/diag"
 	"monkey/object"
+	"monkey/token"
+	"unicode/utf8"
 )
 
 var (
@@ -12,14 +17,88 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// Integers from minCachedInteger up to but excluding maxCachedInteger are
+// preallocated, since small values make up most of what programs compute.
+const (
+	minCachedInteger = -128
+	maxCachedInteger = 1024
+)
+
+var cachedIntegers = func() []object.Integer {
+	integers := make([]object.Integer, maxCachedInteger-minCachedInteger)
+	for i := range integers {
+		integers[i].Value = int64(i + minCachedInteger)
+	}
+	return integers
+}()
+
+// newInteger returns an Integer for value, shared with every other use of the
+// same value when it is small, so the result must never be modified.
+func newInteger(value int64) *object.Integer {
+	if value >= minCachedInteger && value < maxCachedInteger {
+		return &cachedIntegers[value-minCachedInteger]
+	}
+	return &object.Integer{Value: value}
+}
+
+// contextCheckInterval is how many steps pass between checks of the
+// runtime's context, which are too costly to make on every node.
+const contextCheckInterval = 1024
+
+// EvalContext evaluates node like Eval, but stops with an error once ctx is
+// done. The runtime's step and depth counters start again from zero.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment) object.Object {
+	return withContext(ctx, env, func() object.Object {
+		return Eval(node, env)
+	})
+}
+
+// ApplyContext calls a function or builtin value with already evaluated
+// arguments, as a call expression evaluated in env by EvalContext would.
+func ApplyContext(ctx context.Context, fn object.Object, args []object.Object, env *object.Environment) object.Object {
+	return withContext(ctx, env, func() object.Object {
+		return applyFunction(fn, args, env, token.Position{})
+	})
+}
+
+func withContext(ctx context.Context, env *object.Environment, eval func() object.Object) object.Object {
+	rt := env.Runtime()
+	previous := rt.Context
+	rt.Context = ctx
+	rt.Steps = 0
+	rt.Stack = nil
+	rt.Allocated = 0
+	defer func() { rt.Context = previous }()
+
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+	return eval()
+}
+
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := evalNode(node, env)
+	if err, ok := result.(*object.Error); ok && err.Stack == nil {
+		setStack(err, env.Runtime(), node.Pos())
+	}
+	return result
+}
+
+func evalNode(node ast.Node, env *object.Environment) object.Object {
+	if err := step(env.Runtime()); err != nil {
+		return err
+	}
+
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node.Statements, env)
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		if integer, ok := node.Object.(*object.Integer); ok {
+			return integer
+		}
+		return newInteger(node.Value)
 	case *ast.Boolean:
 		return booleanObjectFromBool(node.Value)
 	case *ast.PrefixExpression:
@@ -37,11 +116,15 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return evalInfixExpression(node.Operator, left, right, env)
 	case *ast.BlockStatement:
 		return evalBlockStatement(node.Statements, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.ThrowStatement:
+		return evalThrowStatement(node, env)
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
@@ -53,13 +136,17 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if node.Name.Local {
+			env.SetSlot(node.Name.Slot, val)
+		} else {
+			env.Set(node.Name.Value, val)
+		}
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, Env: env, Body: body, Name: node.Name, Locals: node.Locals}
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
 		if isError(function) {
@@ -69,15 +156,26 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		if node.Tail {
+			return &tailCall{function: function, args: args, pos: node.Pos()}
+		}
+		return applyFunction(function, args, env, node.Pos())
 	case *ast.StringLiteral:
+		if str, ok := node.Object.(*object.String); ok {
+			return str
+		}
 		return &object.String{Value: node.Value}
+	case *ast.TemplateLiteral:
+		return evalTemplateLiteral(node, env)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
-		return &object.Array{Elements: elements}
+		if err := allocate(env, arraySize(len(elements))); err != nil {
+			return err
+		}
+		return object.NewArray(elements)
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
@@ -87,7 +185,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return evalIndexExpression(left, index, env)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 	}
@@ -95,7 +195,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 }
 
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := &object.Hash{}
 	for keyNode, valueNode := range node.Pairs {
 		key := Eval(keyNode, env)
 		if isError(key) {
@@ -104,7 +204,7 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError(object.TypeError, "unusable as hash key: %s", key.Type())
 		}
 
 		value := Eval(valueNode, env)
@@ -112,30 +212,104 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash = hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+	if err := allocate(env, hashSize(hash.Len())); err != nil {
+		return err
+	}
+	return hash
+}
+
+func evalTemplateLiteral(node *ast.TemplateLiteral, env *object.Environment) object.Object {
+	var out bytes.Buffer
+	for i, s := range node.Strings {
+		out.WriteString(s)
+		if i < len(node.Expressions) {
+			value := Eval(node.Expressions[i], env)
+			if isError(value) {
+				return value
+			}
+			text, err := toString(value, env)
+			if err != nil {
+				return err
+			}
+			out.WriteString(text)
+		}
+	}
+	return newString(env, out.String())
+}
+
+// toString is the conversion used wherever a value is turned into text
+// inside a string: strings are used verbatim, anything else as inspected.
+func toString(obj object.Object, env *object.Environment) (string, *object.Error) {
+	if str, ok := obj.(*object.String); ok {
+		return str.Value, nil
 	}
-	return &object.Hash{Pairs: pairs}
+	return inspect(env, obj)
 }
 
-func evalIndexExpression(left, index object.Object) object.Object {
+func evalIndexExpression(left, index object.Object, env *object.Environment) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index, env)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.ERROR_VALUE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalErrorValueIndexExpression(left, index, env)
+	default:
+		return newError(object.TypeError, "index operator not supported %s", left.Type())
+	}
+}
+
+func evalErrorValueIndexExpression(errValue, index object.Object, env *object.Environment) object.Object {
+	err := errValue.(*object.ErrorValue).Err
+	switch index.(*object.String).Value {
+	case "message":
+		return &object.String{Value: err.Message}
+	case "kind":
+		return &object.String{Value: err.KindName()}
+	case "details":
+		if err.Details == nil {
+			return NULL
+		}
+		return detailsHash(err.Details)
+	case "stack":
+		return stringArray(env, err.TraceLines())
 	default:
-		return newError("index operator not supported %s", left.Type())
+		return NULL
 	}
 }
 
+func detailsHash(details map[string]object.Object) *object.Hash {
+	hash := &object.Hash{}
+	for name, value := range details {
+		key := &object.String{Value: name}
+		hash = hash.Set(key.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+	return hash
+}
+
+func hashDetails(hash *object.Hash) (map[string]object.Object, bool) {
+	details := make(map[string]object.Object, hash.Len())
+	for _, pair := range hash.Pairs() {
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			return nil, false
+		}
+		details[key.Value] = pair.Value
+	}
+	return details, true
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError(object.TypeError, "unusable as hash key: %s", index.Type())
 	}
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	pair, ok := hashObject.Get(key.HashKey())
 	if !ok {
 		return NULL
 	}
@@ -145,24 +319,247 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+	max := int64(arrayObject.Len() - 1)
 
 	if idx < 0 || idx > max {
 		return NULL
 	}
-	return arrayObject.Elements[idx]
+	return arrayObject.At(int(idx))
+}
+
+func evalStringIndexExpression(str, index object.Object, env *object.Environment) object.Object {
+	value := str.(*object.String).Value
+	idx := index.(*object.Integer).Value
+
+	if idx < 0 {
+		return NULL
+	}
+	for _, r := range value {
+		if idx == 0 {
+			return newString(env, string(r))
+		}
+		idx--
+	}
+	return NULL
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	var length int64
+	switch left := left.(type) {
+	case *object.String:
+		length = int64(utf8.RuneCountInString(left.Value))
+	case *object.Array:
+		length = int64(left.Len())
+	default:
+		return newError(object.TypeError, "slice operator not supported %s", left.Type())
+	}
+
+	start, err := evalSliceBound(node.Start, env, 0, length)
+	if err != nil {
+		return err
+	}
+	end, err := evalSliceBound(node.End, env, length, length)
+	if err != nil {
+		return err
+	}
+	if start > end {
+		start = end
+	}
+
+	switch left := left.(type) {
+	case *object.String:
+		return newString(env, runeSlice(left.Value, start, end))
+	default:
+		if err := allocate(env, arraySize(0)); err != nil {
+			return err
+		}
+		return left.(*object.Array).Slice(int(start), int(end))
+	}
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// runeSlice returns the runes of s from start up to end without decoding
+// the whole string into a rune slice.
+func runeSlice(s string, start, end int64) string {
+	from, to := len(s), len(s)
+	var i int64
+	for offset := range s {
+		if i == start {
+			from = offset
+		}
+		if i == end {
+			to = offset
+			break
+		}
+		i++
+	}
+	return s[from:to]
+}
+
+func evalSliceBound(node ast.Expression, env *object.Environment, fallback, length int64) (int64, object.Object) {
+	if node == nil {
+		return fallback, nil
+	}
+	bound := Eval(node, env)
+	if isError(bound) {
+		return 0, bound
+	}
+	integer, ok := bound.(*object.Integer)
+	if !ok {
+		return 0, newError(object.TypeError, "slice bound must be INTEGER, got %s", bound.Type())
+	}
+	switch {
+	case integer.Value < 0:
+		return 0, nil
+	case integer.Value > length:
+		return length, nil
+	default:
+		return integer.Value, nil
+	}
+}
+
+func step(rt *object.Runtime) *object.Error {
+	rt.Steps++
+	if rt.MaxSteps > 0 && rt.Steps > rt.MaxSteps {
+		return newLimitError("step limit exceeded")
+	}
+	if rt.Context != nil && rt.Steps%contextCheckInterval == 0 {
+		return checkContext(rt.Context)
+	}
+	return nil
+}
+
+func checkContext(ctx context.Context) *object.Error {
+	switch ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return newLimitError("execution timed out")
+	default:
+		return newLimitError("execution cancelled")
+	}
+}
+
+// Diagnostic describes err at the place it was raised, with the calls that
+// led there as notes.
+func Diagnostic(err *object.Error) diag.Diagnostic {
+	d := diag.Diagnostic{Severity: diag.Error, Message: err.Message, Code: err.KindName()}
+	if len(err.Stack) > 0 {
+		d.Span = diag.Span{Start: err.Stack[0].Pos}
+		d.Notes = err.TraceLines()
+	}
+	return d
+}
+
+// setStack snapshots the runtime's call stack onto err, raised at pos,
+// innermost call first. Each frame's position is where execution had got to
+// in that function: pos for the innermost, then the call site of the frame
+// above it. Only MaxTraceFrames are kept from each end of a deep stack, so
+// raising an error costs the same however deep the recursion.
+func setStack(err *object.Error, rt *object.Runtime, pos token.Position) {
+	depth := len(rt.Stack) + 1
+	keep := depth
+	if depth > 2*object.MaxTraceFrames {
+		keep = 2 * object.MaxTraceFrames
+		err.Elided = depth - keep
+	}
+	frames := make([]object.Frame, 0, keep)
+	for i := 0; i < depth; i++ {
+		if err.Elided > 0 && i == object.MaxTraceFrames {
+			i += err.Elided
+		}
+		frame := object.Frame{Function: "<main>", Pos: pos}
+		if i < len(rt.Stack) {
+			frame.Function = rt.Stack[len(rt.Stack)-1-i].Function
+			frame.TailCalls = rt.Stack[len(rt.Stack)-1-i].TailCalls
+		}
+		if i > 0 {
+			frame.Pos = rt.Stack[len(rt.Stack)-i].Pos
+		}
+		frames = append(frames, frame)
+	}
+	err.Stack = frames
+}
+
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+	return fn.Name
+}
+
+// tailCall is what a call in tail position evaluates to: rather than nest a
+// Go call, callFunction makes it in a loop once the caller's body is done.
+type tailCall struct {
+	function object.Object
+	args     []object.Object
+	pos      token.Position
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+func applyFunction(fn object.Object, args []object.Object, env *object.Environment, pos token.Position) object.Object {
 	switch function := fn.(type) {
 	case *object.Function:
-		extendedEnv := extendedFunctionEnv(function, args)
-		evaluated := Eval(function.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		rt := env.Runtime()
+		if rt.MaxDepth > 0 && len(rt.Stack) >= rt.MaxDepth {
+			return newLimitError("stack overflow")
+		}
+		if len(args) != len(function.Parameters) {
+			return arityError(functionName(function), len(function.Parameters), len(args))
+		}
+		rt.Stack = append(rt.Stack, object.Frame{Function: functionName(function), Pos: pos})
+		defer func() { rt.Stack = rt.Stack[:len(rt.Stack)-1] }()
+		return callFunction(function, args, env)
 	case *object.Builtin:
-		return function.Fn(args...)
+		if !env.Runtime().Capabilities.Has(function.Capability) {
+			err := newError(object.PermissionError, "permission denied: `%s` requires the %s capability", function.Name, function.Capability)
+			err.Details = map[string]object.Object{"capability": &object.String{Value: function.Capability.String()}}
+			return err
+		}
+		if result := function.Fn(env, args...); result != nil {
+			return result
+		}
+		return NULL
 	default:
-		return newError("not a function: %s", function.Type())
+		return newError(object.TypeError, "not a function: %s", function.Type())
+	}
+}
+
+// callFunction runs function in the frame on top of the stack, running any
+// tail calls it ends with in that same frame, which only counts them.
+func callFunction(function *object.Function, args []object.Object, env *object.Environment) object.Object {
+	rt := env.Runtime()
+	for {
+		extendedEnv := extendedFunctionEnv(function, args)
+		evaluated := unwrapReturnValue(Eval(function.Body, extendedEnv))
+		call, ok := evaluated.(*tailCall)
+		if !ok {
+			return evaluated
+		}
+
+		next, ok := call.function.(*object.Function)
+		if !ok {
+			result := applyFunction(call.function, call.args, extendedEnv, call.pos)
+			if err, ok := result.(*object.Error); ok && err.Stack == nil {
+				setStack(err, rt, call.pos)
+			}
+			return result
+		}
+		if len(call.args) != len(next.Parameters) {
+			err := arityError(functionName(next), len(next.Parameters), len(call.args))
+			setStack(err, rt, call.pos)
+			return err
+		}
+		frame := &rt.Stack[len(rt.Stack)-1]
+		frame.Function = functionName(next)
+		frame.TailCalls++
+		function, args = next, call.args
 	}
 }
 
@@ -174,15 +571,22 @@ func unwrapReturnValue(obj object.Object) object.Object {
 }
 
 func extendedFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
-	for index, param := range fn.Parameters {
-		env.Set(param.Value, args[index])
+	locals := fn.Locals
+	if locals == nil {
+		locals = make([]string, len(fn.Parameters))
+		for i, param := range fn.Parameters {
+			locals[i] = param.Value
+		}
+	}
+	env := object.NewSlotEnvironment(fn.Env, locals)
+	for index, arg := range args {
+		env.SetSlot(index, arg)
 	}
 	return env
 }
 
 func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
-	var result []object.Object
+	result := make([]object.Object, 0, len(exps))
 	for _, e := range exps {
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
@@ -194,13 +598,22 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
-	if val, ok := env.Get(node.Value); ok {
+	if node.Local {
+		if val, ok := env.GetSlot(node.Depth, node.Slot, node.Value); ok {
+			return val
+		}
+	} else if val, ok := env.Get(node.Value); ok {
 		return val
 	}
+	if builtin, ok := env.Runtime().Builtins[node.Value]; ok {
+		return builtin
+	}
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin
 	}
-	return newError("identifier not found: %s", node.Value)
+	err := newError(object.NameError, "identifier not found: %s", node.Value)
+	err.Details = map[string]object.Object{"name": &object.String{Value: node.Value}}
+	return err
 }
 
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
@@ -237,33 +650,34 @@ func booleanObjectFromBool(value bool) object.Object {
 	return FALSE
 }
 
-func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+func evalInfixExpression(operator string, left object.Object, right object.Object, env *object.Environment) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right)
+		return evalStringInfixExpression(operator, left, right, env)
 	case operator == "==":
 		return booleanObjectFromBool(left == right)
 	case operator == "!=":
 		return booleanObjectFromBool(left != right)
-	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return infixOperatorError(operator, left, right)
 	}
 
 }
 
-func evalStringInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+func evalStringInfixExpression(operator string, left object.Object, right object.Object, env *object.Environment) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
 
 	switch operator {
 	case "+":
+		if err := allocate(env, stringSize(len(leftVal)+len(rightVal))); err != nil {
+			return err
+		}
 		return &object.String{Value: leftVal + rightVal}
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return infixOperatorError(operator, left, right)
 	}
 }
 
@@ -273,13 +687,16 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 
 	switch operator {
 	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
+		return newInteger(leftVal + rightVal)
 	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
+		return newInteger(leftVal - rightVal)
 	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
+		return newInteger(leftVal * rightVal)
 	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+		if rightVal == 0 {
+			return newError(object.ValueError, "division by zero")
+		}
+		return newInteger(leftVal / rightVal)
 	case "<":
 		return booleanObjectFromBool(leftVal < rightVal)
 	case ">":
@@ -289,7 +706,7 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "!=":
 		return booleanObjectFromBool(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return infixOperatorError(operator, left, right)
 	}
 }
 
@@ -300,7 +717,7 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(object.TypeError, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -319,10 +736,53 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
 	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+		return newError(object.TypeError, "unknown operator: -%s", right.Type())
 	}
 	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	return newInteger(-value)
+}
+
+// evalTryExpression runs the catch block, if any, when the try block fails
+// with a catchable error, then the finally block, if any. A value returned
+// or an error raised by the finally block takes precedence; fatal errors skip
+// both blocks.
+func evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	result := Eval(te.Block, env)
+	if err, ok := result.(*object.Error); ok && !err.Fatal && te.Catch != nil {
+		locals := te.CatchLocals
+		if locals == nil {
+			locals = []string{te.Parameter.Value}
+		}
+		catchEnv := object.NewSlotEnvironment(env, locals)
+		catchEnv.SetSlot(0, &object.ErrorValue{Err: err})
+		result = Eval(te.Catch, catchEnv)
+	}
+	if err, ok := result.(*object.Error); ok && err.Fatal || te.Finally == nil {
+		return result
+	}
+
+	finally := Eval(te.Finally, env)
+	if finally != nil {
+		if rt := finally.Type(); rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			return finally
+		}
+	}
+	return result
+}
+
+func evalThrowStatement(ts *ast.ThrowStatement, env *object.Environment) object.Object {
+	val := Eval(ts.Value, env)
+	if isError(val) {
+		return val
+	}
+	switch val := val.(type) {
+	case *object.ErrorValue:
+		return val.Err
+	case *object.String:
+		return &object.Error{Kind: object.GenericError, Message: val.Value}
+	default:
+		return newError(object.TypeError, "cannot throw %s, expected STRING or ERROR_VALUE", val.Type())
+	}
 }
 
 func evalProgram(statements []ast.Statement, env *object.Environment) object.Object {
@@ -357,8 +817,38 @@ func evalBlockStatement(statements []ast.Statement, env *object.Environment) obj
 	return result
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(kind string, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
+
+// newLimitError reports an exceeded limit, which scripts cannot catch.
+func newLimitError(format string, a ...interface{}) *object.Error {
+	err := newError(object.LimitError, format, a...)
+	err.Fatal = true
+	return err
+}
+
+func infixOperatorError(operator string, left, right object.Object) *object.Error {
+	format := "unknown operator: %s %s %s"
+	if left.Type() != right.Type() {
+		format = "type mismatch: %s %s %s"
+	}
+	err := newError(object.TypeError, format, left.Type(), operator, right.Type())
+	err.Details = map[string]object.Object{
+		"operator": &object.String{Value: operator},
+		"left":     &object.String{Value: string(left.Type())},
+		"right":    &object.String{Value: string(right.Type())},
+	}
+	return err
+}
+
+func arityError(name string, expected, got int) *object.Error {
+	err := newError(object.ArityError, "`%s` takes %d argument(s), got %d", name, expected, got)
+	err.Details = map[string]object.Object{
+		"expected": newInteger(int64(expected)),
+		"got":      newInteger(int64(got)),
+	}
+	return err
 }
 
 func isError(obj object.Object) bool {
@@ -1,9 +1,14 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"monkey/ast"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/resolver"
+	"strings"
 	"testing"
 )
 
@@ -31,6 +36,7 @@ func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
+	resolver.Resolve(program)
 	env := object.NewEnvironment()
 
 	return Eval(program, env)
@@ -344,7 +350,7 @@ func TestBuiltinFunctions(t *testing.T) {
 			if !ok {
 				t.Fatalf("obj is not an array, got=%s", result.Inspect())
 			}
-			for _, e := range result.Elements {
+			for _, e := range result.Elements() {
 				integer := e.(*object.Integer)
 				if integer.Value != int64(expected[0]) {
 					t.Fatalf("unexpected value, wanted=%d got=%d", integer.Value, integer)
@@ -362,12 +368,12 @@ func TestArrayLiterals(t *testing.T) {
 	if !ok {
 		t.Fatalf("not an array")
 	}
-	if len(result.Elements) != 3 {
+	if result.Len() != 3 {
 		t.Fatalf("length incorrect")
 	}
-	testIntegerObject(t, result.Elements[0], 1)
-	testIntegerObject(t, result.Elements[1], 4)
-	testIntegerObject(t, result.Elements[2], 6)
+	testIntegerObject(t, result.At(0), 1)
+	testIntegerObject(t, result.At(1), 4)
+	testIntegerObject(t, result.At(2), 6)
 }
 
 func TestArrayIndexExpressions(t *testing.T) {
@@ -419,11 +425,11 @@ func TestHashLiterals(t *testing.T) {
 		TRUE.HashKey():                             5,
 		FALSE.HashKey():                            6,
 	}
-	if len(result.Pairs) != len(expected) {
+	if result.Len() != len(expected) {
 		t.Fatalf("incorrect number of pairs found")
 	}
 	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		pair, ok := result.Get(expectedKey)
 		if !ok {
 			t.Fatalf("No pair for given key in Paira")
 		}
@@ -451,3 +457,628 @@ func TestHashLiteralExpression(t *testing.T) {
 		}
 	}
 }
+
+func TestStringIndexAndSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[4]`, "o"},
+		{`"héllo"[5]`, nil},
+		{`"héllo"[-1]`, nil},
+		{`"héllo"[1:3]`, "él"},
+		{`"héllo"[:2]`, "hé"},
+		{`"héllo"[3:]`, "lo"},
+		{`"héllo"[:]`, "héllo"},
+		{`"héllo"[4:2]`, ""},
+		{`"héllo"[-3:100]`, "héllo"},
+		{`len([1, 2, 3][1:])`, 2},
+		{`[1, 2, 3][1:2][0]`, 2},
+		{`"abc"["a":]`, "slice bound must be INTEGER, got STRING"},
+		{`5[1:]`, "slice operator not supported INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testStringOrError(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestStringBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("héllo")`, 5},
+		{`len(split("a,b,c", ","))`, 3},
+		{`split("a,b,c", ",")[2]`, "c"},
+		{`join(["a", "b", "c"], "-")`, "a-b-c"},
+		{`join(["a", 1], "-")`, "`join` takes an array of strings, got INTEGER"},
+		{`trim("  hi  ")`, "hi"},
+		{`trim("xxhixx", "x")`, "hi"},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("HÉLLO")`, "héllo"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`contains("monkey", "key")`, true},
+		{`contains("monkey", "donkey")`, false},
+		{`starts_with("monkey", "mon")`, true},
+		{`ends_with("monkey", "mon")`, false},
+		{`index_of("héllo", "l")`, 2},
+		{`index_of("héllo", "z")`, -1},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", -1)`, "`repeat` count must not be negative, got -1"},
+		{`len(chars("héllo"))`, 5},
+		{`chars("héllo")[1]`, "é"},
+		{`ord("é")`, 233},
+		{`ord("ab")`, "`ord` takes a single character, got 2"},
+		{`chr(233)`, "é"},
+		{`chr(-1)`, "`chr` code point out of range: -1"},
+		{`upper(1)`, "argument 1 to `upper` must be STRING, got INTEGER"},
+		{`upper("a", "b")`, "`upper` takes 1 argument(s), got 2"},
+		{`format("%s has %d items: %v", "cart", 3, [1, 2])`, "cart has 3 items: [1, 2]"},
+		{`sprintf("%q %t %05d %x", "a", true, 42, 255)`, `"a" true 00042 ff`},
+		{`format(1)`, "argument 1 to `format` must be STRING, got INTEGER"},
+		{`sprintf()`, "`sprintf` takes at least one argument"},
+		{`gets(1)`, "`gets` takes no arguments"},
+		{`read_line(1)`, "`read_line` takes no arguments"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			testStringOrError(t, evaluated, expected)
+		}
+	}
+}
+
+func testStringOrError(t *testing.T, obj object.Object, expected string) {
+	switch result := obj.(type) {
+	case *object.String:
+		if result.Value != expected {
+			t.Fatalf("unexpected string.Value, expected=%q, got=%q", expected, result.Value)
+		}
+	case *object.Error:
+		if result.Message != expected {
+			t.Fatalf("wrong error message, expected=%q, got=%q", expected, result.Message)
+		}
+	default:
+		t.Fatalf("obj is not a string or error, got=%s", obj.Inspect())
+	}
+}
+
+func TestTemplateLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"plain"`, "plain"},
+		{`let user = {"name": "Ana"}; let items = [1, 2]; "hello ${user["name"]}, you have ${len(items)} items"`, "hello Ana, you have 2 items"},
+		{`"${true} ${[1, "a"]} ${if (false) { 1 }}"`, "true [1, a] null"},
+		{`let greet = fn(n) { "hi ${n}" }; "${greet("${1 + 1}")}!"`, "hi 2!"},
+		{`"${1 + true}"`, "type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		testStringOrError(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestIOBuiltins(t *testing.T) {
+	input := `
+let name = gets();
+print("hello ", name);
+puts("!", 1);
+let next = read_line();
+let last = read_line();
+puts(next, last);
+`
+	var out bytes.Buffer
+	runtime := object.NewRuntime()
+	runtime.SetInput(strings.NewReader("monkey\r\nsecond"))
+	runtime.Stdout = &out
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	Eval(program, object.NewEnvironmentWithRuntime(runtime))
+
+	expected := "hello monkey!\n1\nsecond\nnull\n"
+	if out.String() != expected {
+		t.Fatalf("wrong output, expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestEvalContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	program := parser.New(lexer.New("1 + 1")).ParseProgram()
+	evaluated := EvalContext(ctx, program, object.NewEnvironment())
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || errObj.Message != "execution cancelled" {
+		t.Fatalf("expected cancellation error, got=%v", evaluated)
+	}
+}
+
+func TestErrorStackTraces(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"1 + true", []string{"at <main> (1:1)"}},
+		{
+			"let add = fn(a, b) { a + b };\nadd(1, true)",
+			[]string{"at add (1:22)", "at <main> (2:1)"},
+		},
+		{
+			"let apply = fn(f) { let result = f(); result };\napply(fn() { len(1) })",
+			[]string{"at <anonymous> (2:14)", "at apply (1:34)", "at <main> (2:1)"},
+		},
+		{
+			"let apply = fn(f) { f() };\napply(fn() { len(1) })",
+			[]string{"at <anonymous> (2:14)", "... 1 tail call elided", "at <main> (2:1)"},
+		},
+		{
+			"let f = fn(n) { if (n == 0) { len(1) } else { f(n - 1) } };\nf(100000)",
+			[]string{"at f (1:31)", "... 100000 tail calls elided", "at <main> (2:1)"},
+		},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Fatalf("expected an error for %q", tt.input)
+		}
+		lines := errObj.TraceLines()
+		if len(lines) != len(tt.expected) {
+			t.Fatalf("wrong number of lines for %q. expected=%d, got=%q",
+				tt.input, len(tt.expected), errObj.StackTrace())
+		}
+		for i, line := range lines {
+			if line != tt.expected[i] {
+				t.Errorf("wrong line %d for %q. expected=%q, got=%q",
+					i, tt.input, tt.expected[i], line)
+			}
+		}
+	}
+}
+
+func TestDeepStackTraces(t *testing.T) {
+	input := "let f = fn(n) { if (n == 0) { len(1) } else { 1 + f(n - 1) } };\nf(100)"
+	errObj, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("expected an error")
+	}
+	if len(errObj.Stack) != 2*object.MaxTraceFrames || errObj.Elided != 82 {
+		t.Fatalf("expected %d frames and 82 elided, got=%d and %d", 2*object.MaxTraceFrames, len(errObj.Stack), errObj.Elided)
+	}
+	lines := errObj.TraceLines()
+	expected := map[int]string{0: "at f (1:31)", 1: "at f (1:51)", 10: "... 82 more frames", 19: "at f (1:51)", 20: "at <main> (2:1)"}
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("wrong line %d. expected=%q, got=%q", i, line, lines[i])
+		}
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { 1 + true } catch (e) { 2 }`, 2},
+		{`try { throw "boom" } catch (e) { e["message"] }`, "boom"},
+		{`try { throw "boom" } catch (e) { e["kind"] }`, "Error"},
+		{`try { len(1) } catch (e) { e["message"] }`, "argument to `len` not supported, got INTEGER"},
+		{`try { {}["a"]["b"] } catch (e) { e["message"] }`, "index operator not supported NULL"},
+		{`try { throw "inner" } catch (e) { try { throw e } catch (again) { again["message"] } }`, "inner"},
+		{`let f = fn() { throw "deep" }; try { f() } catch (e) { len(e["stack"]) }`, 2},
+		{`let f = fn() { throw "deep" }; try { f() } catch (e) { e["stack"][0] }`, "at f (1:16)"},
+		{`let x = 0; try { 1 } finally { let x = 5 }; x`, 5},
+		{`try { throw "a" } catch (e) { throw "b" }`, "b"},
+		{`try { throw "a" } finally { 1 }`, "a"},
+		{`try { throw "a" } catch (e) { 1 } finally { throw "c" }`, "c"},
+		{`let f = fn() { try { return 1 } finally { 2 } }; f()`, 1},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{`try { throw 1 } catch (e) { e["message"] }`, "cannot throw INTEGER, expected STRING or ERROR_VALUE"},
+		{`try { throw "x" } catch (e) { e }`, "Error: x"},
+		{`try { throw "x" } catch (e) { 1 }; e`, "identifier not found: e"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			var got string
+			switch evaluated := evaluated.(type) {
+			case *object.String:
+				got = evaluated.Value
+			case *object.Error:
+				got = evaluated.Message
+			case *object.ErrorValue:
+				got = evaluated.Inspect()
+			default:
+				t.Errorf("%s: unexpected object %T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if got != expected {
+				t.Errorf("%s: expected=%q, got=%q", tt.input, expected, got)
+			}
+		}
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1 + true`, object.TypeError},
+		{`"a" - "b"`, object.TypeError},
+		{`-true`, object.TypeError},
+		{`5()`, object.TypeError},
+		{`foobar`, object.NameError},
+		{`len("a", "b")`, object.ArityError},
+		{`upper()`, object.ArityError},
+		{`fn(a, b) { a }(1)`, object.ArityError},
+		{`repeat("a", -1)`, object.ValueError},
+		{`1 / 0`, object.ValueError},
+		{`throw "boom"`, object.GenericError},
+		{`try { throw "boom" } catch (e) { throw e }`, object.GenericError},
+		{`throw error("MyError", "boom")`, "MyError"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Fatalf("%s: expected an error", tt.input)
+		}
+		if errObj.Kind != tt.expected {
+			t.Errorf("%s: wrong kind. expected=%q, got=%q (%s)", tt.input, tt.expected, errObj.Kind, errObj.Message)
+		}
+	}
+}
+
+func TestErrorBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`error("ValueError", "bad input")`, "ValueError: bad input"},
+		{`error("ValueError", "bad input")["kind"]`, "ValueError"},
+		{`error("ValueError", "bad", {"field": "age"})["details"]["field"]`, "age"},
+		{`error("ValueError", "bad")["details"]`, nil},
+		{`error("ValueError")`, "`error` takes 2 argument(s), got 1"},
+		{`error("ValueError", "bad", {1: 2})`, "`error` details must have STRING keys"},
+		{`is_error(error("ValueError", "bad"))`, true},
+		{`is_error("bad")`, false},
+		{`is_error(try { 1 + true } catch (e) { e })`, true},
+		{`try { 1 + true } catch (e) { e["details"]["operator"] }`, "+"},
+		{`try { x } catch (e) { e["details"]["name"] }`, "x"},
+		{`try { upper() } catch (e) { e["details"]["expected"] }`, 1},
+		{`let check = fn(n) { if (n < 0) { return error("RangeError", "negative") } n }; is_error(check(-1))`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			var got string
+			switch evaluated := evaluated.(type) {
+			case *object.String:
+				got = evaluated.Value
+			case *object.Error:
+				got = evaluated.Message
+			default:
+				got = evaluated.Inspect()
+			}
+			if got != expected {
+				t.Errorf("%s: expected=%q, got=%q", tt.input, expected, got)
+			}
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let loop = fn(n, acc) { if (n == 0) { acc } else { loop(n - 1, acc + n) } }; loop(100000, 0)", 5000050000},
+		{"let loop = fn(n) { if (n == 0) { return 0; } return loop(n - 1); }; loop(100000)", 0},
+		{"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(100001)", false},
+		{"let loop = fn(n) { if (n == 0) { len(\"done\") } else { loop(n - 1) } }; loop(100000)", 4},
+		{"let loop = fn(n) { if (n == 0) { 0 } else { try { throw \"next\" } catch (e) { loop(n - 1) } } }; loop(100000)", 0},
+		{"let loop = fn(n) { try { 1 / n; n } catch (e) { return loop(n - 1); } }; loop(0)", -1},
+		{"let loop = fn(n) { if (n == 0) { 0 } else { 1 + loop(n - 1) } }; loop(100000)", "stack overflow"},
+		{"let loop = fn(n) { if (n == 0) { 0 } else { try { loop(n - 1) } catch (e) { 0 } } }; loop(100000)", "stack overflow"},
+		{"let f = fn(a) { a }; let g = fn() { f() }; g()", "`f` takes 1 argument(s), got 0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok || errObj.Message != expected {
+				t.Errorf("%s: expected error %q, got=%v", tt.input, expected, evaluated)
+			}
+		}
+	}
+}
+
+func TestTailCallDepth(t *testing.T) {
+	program := parser.New(lexer.New("let loop = fn(n) { if (n == 0) { depth() } else { loop(n - 1) } }; loop(100000)")).ParseProgram()
+	resolver.Resolve(program)
+	env := object.NewEnvironment()
+	env.Set("depth", &object.Builtin{Name: "depth", Fn: func(env *object.Environment, args ...object.Object) object.Object {
+		return &object.Integer{Value: int64(len(env.Runtime().Stack))}
+	}})
+	testIntegerObject(t, Eval(program, env), 1)
+	if len(env.Runtime().Stack) != 0 {
+		t.Fatalf("expected an empty stack, got=%v", env.Runtime().Stack)
+	}
+}
+
+func TestResolvedScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 1; let f = fn() { let y = x; let x = 2; y + x }; f()", 3},
+		{"let f = fn() { let even = fn(n) { if (n == 0) { 1 } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { 0 } else { even(n - 1) } }; even(10) }; f()", 1},
+		{"let adder = fn(a) { fn(b) { fn(c) { a + b + c } } }; adder(1)(2)(3)", 6},
+		{"let f = fn(a, a) { a }; f(1, 2)", 2},
+		{"let f = fn(n) { if (n > 0) { let m = n * 2; m } else { 0 } }; f(4)", 8},
+		{"let f = fn() { let x = 1; let x = x + 1; x }; f()", 2},
+		{"let f = fn() { let len = fn(s) { 42 }; len(\"a\") }; f()", 42},
+		{"let f = fn() { let n = len(\"ab\"); let len = 0; n }; f()", 2},
+		{"let f = fn(x) { try { throw \"e\" } catch (e) { let y = 10; x + y } }; f(1)", 11},
+		{"let f = fn(x) { try { throw \"e\" } catch (e) { let x = 5; x }; x }; f(1)", 1},
+		{"let g = 10; let f = fn() { g }; let g = 20; f()", 20},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestCollectionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`push([1, 2], 3)`, "[1, 2, 3]"},
+		{`let a = [1, 2]; let b = push(a, 3); a`, "[1, 2]"},
+		{`let a = [1, 2, 3]; let b = push(rest(a), 4); [a, b]`, "[[1, 2, 3], [2, 3, 4]]"},
+		{`let a = [1, 2, 3]; let b = push(a[0:1], 9); [a, b]`, "[[1, 2, 3], [1, 9]]"},
+		{`let a = [1, 2, 3]; let b = set(a, 1, 5); [a, b]`, "[[1, 2, 3], [1, 5, 3]]"},
+		{`set([1], 1, 2)`, "`set` index 1 out of range for array of length 1"},
+		{`set([1], "a", 2)`, "argument 2 to `set` must be INTEGER, got STRING"},
+		{`let h = {"a": 1}; let g = set(h, "b", 2); [h["b"], g["b"], g["a"]]`, "[null, 2, 1]"},
+		{`let h = {"a": 1, "b": 2}; let g = delete(h, "a"); [h["a"], g["a"], g["b"]]`, "[1, null, 2]"},
+		{`delete({}, fn() {})`, "unusable as hash key: FUNCTION"},
+		{`push(1, 2)`, "argument 1 to `push` must be ARRAY, got INTEGER"},
+		{`set(1, 2, 3)`, "argument 1 to `set` must be ARRAY or HASH, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		got := evaluated.Inspect()
+		if errObj, ok := evaluated.(*object.Error); ok {
+			got = errObj.Message
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestRecursionOverLargeArrays(t *testing.T) {
+	input := `
+let map = fn(arr, f, acc) {
+	if (len(arr) == 0) { acc } else { map(rest(arr), f, push(acc, f(first(arr)))) }
+};
+let sum = fn(arr, acc) {
+	if (len(arr) == 0) { acc } else { sum(rest(arr), acc + first(arr)) }
+};
+let ones = map(split(repeat("a", 100000), ""), fn(s) { len(s) }, []);
+sum(ones, 0)`
+
+	testIntegerObject(t, testEval(input), 100000)
+}
+
+func TestIntegerCache(t *testing.T) {
+	for _, value := range []int64{minCachedInteger - 1, minCachedInteger, -1, 0, 1, maxCachedInteger - 1, maxCachedInteger} {
+		integer := newInteger(value)
+		if integer.Value != value {
+			t.Fatalf("newInteger(%d) has value %d", value, integer.Value)
+		}
+		cached := value >= minCachedInteger && value < maxCachedInteger
+		if shared := newInteger(value) == integer; shared != cached {
+			t.Errorf("newInteger(%d): expected shared=%t, got=%t", value, cached, shared)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		evalIntegerInfixExpression("+", newInteger(40), newInteger(2))
+	})
+	if allocs != 0 {
+		t.Errorf("expected small integer arithmetic not to allocate, got %.0f allocations", allocs)
+	}
+}
+
+func TestLiteralObjects(t *testing.T) {
+	program := parser.New(lexer.New(`[100000, "monkey"]`)).ParseProgram()
+	resolver.Resolve(program)
+	literals := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.ArrayLiteral).Elements
+	env := object.NewEnvironment()
+	for _, literal := range literals {
+		if first, second := Eval(literal, env), Eval(literal, env); first != second {
+			t.Errorf("expected %s to evaluate to the same object each time", literal)
+		}
+		allocs := testing.AllocsPerRun(100, func() { Eval(literal, env) })
+		if allocs != 0 {
+			t.Errorf("expected evaluating %s not to allocate, got %.0f allocations", literal, allocs)
+		}
+	}
+}
+
+const fibProgram = "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(20)"
+
+func TestBuiltinDocs(t *testing.T) {
+	for _, name := range BuiltinNames() {
+		if doc, ok := BuiltinDoc(name); !ok || !strings.HasPrefix(doc, name+"(") {
+			t.Errorf("builtin %s has no documentation starting with its signature, got=%q", name, doc)
+		}
+	}
+	if _, ok := BuiltinDoc("nope"); ok {
+		t.Errorf("expected no documentation for an unknown name")
+	}
+}
+
+func BenchmarkFib(b *testing.B) {
+	benchmarkProgram(b, fibProgram)
+}
+
+func BenchmarkFibUnresolved(b *testing.B) {
+	benchmarkUnresolvedProgram(b, fibProgram)
+}
+
+// localsProgram loops in a function with many locals, reading names declared
+// at each level of three nested scopes, so every lookup the resolver settles
+// would otherwise compare names along the way. Globals are looked up by name
+// either way, so BenchmarkFib shows little of the difference.
+const localsProgram = `let f = fn(a, b, c, d) {
+	let e = 1; let g = 2; let h = 3; let i = 4;
+	let inner = fn(j, k) {
+		let l = 5; let m = 6;
+		let loop = fn(n, acc) {
+			let o = 7; let p = 8;
+			if (n == 0) { acc } else { loop(n - 1, acc + a + e + j + l + o + p) }
+		};
+		loop(1000, 0)
+	};
+	inner(b, c)
+};
+f(1, 2, 3, 4)`
+
+func BenchmarkLocals(b *testing.B) {
+	benchmarkProgram(b, localsProgram)
+}
+
+func BenchmarkLocalsUnresolved(b *testing.B) {
+	benchmarkUnresolvedProgram(b, localsProgram)
+}
+
+func BenchmarkClosureLookup(b *testing.B) {
+	input := "let f = fn(a) { let g = fn(b) { let h = fn(n) { if (n == 0) { a + b } else { h(n - 1) } }; h(1000) }; g(2) }; f(1)"
+	benchmarkProgram(b, input)
+}
+
+func BenchmarkIntegerArithmetic(b *testing.B) {
+	input := "let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n * 2 - 1) } }; sum(1000, 0)"
+	benchmarkProgram(b, input)
+}
+
+// largeLiteralProgram evaluates two literals outside the small integer cache
+// on every iteration, which the resolver makes once instead of each time.
+const largeLiteralProgram = "let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + 1000000 - 999999) } }; sum(1000, 0)"
+
+func BenchmarkIntegerLiterals(b *testing.B) {
+	benchmarkProgram(b, largeLiteralProgram)
+}
+
+func BenchmarkIntegerLiteralsUncached(b *testing.B) {
+	program := parser.New(lexer.New(largeLiteralProgram)).ParseProgram()
+	resolver.Resolve(program)
+	forgetLiterals(program)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Eval(program, object.NewEnvironment())
+	}
+}
+
+// forgetLiterals drops the objects the resolver made for the integer
+// literals in node, going through the nodes largeLiteralProgram uses.
+func forgetLiterals(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, stmt := range node.Statements {
+			forgetLiterals(stmt)
+		}
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			forgetLiterals(stmt)
+		}
+	case *ast.LetStatement:
+		forgetLiterals(node.Value)
+	case *ast.ExpressionStatement:
+		forgetLiterals(node.Expression)
+	case *ast.FunctionLiteral:
+		forgetLiterals(node.Body)
+	case *ast.IfExpression:
+		forgetLiterals(node.Condition)
+		forgetLiterals(node.Consequence)
+		if node.Alternative != nil {
+			forgetLiterals(node.Alternative)
+		}
+	case *ast.InfixExpression:
+		forgetLiterals(node.Left)
+		forgetLiterals(node.Right)
+	case *ast.CallExpression:
+		for _, arg := range node.Arguments {
+			forgetLiterals(arg)
+		}
+	case *ast.IntegerLiteral:
+		node.Object = nil
+	}
+}
+
+func BenchmarkArrayLiterals(b *testing.B) {
+	benchmarkProgram(b, "let f = fn(n) { if (n == 0) { [] } else { [n, n + 1, n + 2]; f(n - 1) } }; f(1000)")
+}
+
+func benchmarkUnresolvedProgram(b *testing.B, input string) {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Eval(program, object.NewEnvironment())
+	}
+}
+
+func benchmarkProgram(b *testing.B, input string) {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	resolver.Resolve(program)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Eval(program, object.NewEnvironment())
+	}
+}
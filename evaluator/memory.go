@@ -0,0 +1,96 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"strings"
+)
+
+// Approximate sizes in bytes of the values scripts allocate. They only need
+// to be good enough to stop a script that grows without bound, not to match
+// the Go heap exactly.
+const (
+	stringHeaderSize = 32
+	arrayHeaderSize  = 40
+	arrayElementSize = 16
+	hashHeaderSize   = 48
+	hashPairSize     = 56
+)
+
+func stringSize(length int) int64 {
+	return stringHeaderSize + int64(length)
+}
+
+func arraySize(length int) int64 {
+	return arrayHeaderSize + int64(length)*arrayElementSize
+}
+
+func hashSize(pairs int) int64 {
+	return hashHeaderSize + int64(pairs)*hashPairSize
+}
+
+// allocate charges size bytes to the runtime's allocation budget. It is called
+// before building a value wherever the size is known up front, so that an
+// oversized string or array is refused instead of materialized.
+func allocate(env *object.Environment, size int64) *object.Error {
+	rt := env.Runtime()
+	rt.Allocated += size
+	if rt.MaxMemory > 0 && rt.Allocated > rt.MaxMemory {
+		return newLimitError("memory limit exceeded")
+	}
+	return nil
+}
+
+func newString(env *object.Environment, value string) object.Object {
+	if err := allocate(env, stringSize(len(value))); err != nil {
+		return err
+	}
+	return &object.String{Value: value}
+}
+
+// inspect renders obj as its Inspect method would, but builds arrays and
+// hashes piece by piece and gives up once the text outgrows what is left of
+// the allocation budget. Arrays and hashes share structure, so a value that
+// was cheap to build can inspect to far more text than it occupies.
+func inspect(env *object.Environment, obj object.Object) (string, *object.Error) {
+	rt := env.Runtime()
+	if rt.MaxMemory <= 0 {
+		return obj.Inspect(), nil
+	}
+	var out strings.Builder
+	if !writeInspect(&out, obj, rt.MaxMemory-rt.Allocated) {
+		return "", newLimitError("memory limit exceeded")
+	}
+	return out.String(), nil
+}
+
+func writeInspect(out *strings.Builder, obj object.Object, limit int64) bool {
+	switch obj := obj.(type) {
+	case *object.Array:
+		out.WriteString("[")
+		for i := 0; i < obj.Len(); i++ {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			if !writeInspect(out, obj.At(i), limit) {
+				return false
+			}
+		}
+		out.WriteString("]")
+	case *object.Hash:
+		out.WriteString("{")
+		for i, pair := range obj.Pairs() {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			out.WriteString(pair.Key.Inspect())
+			out.WriteString(": ")
+			if !writeInspect(out, pair.Value, limit) {
+				return false
+			}
+		}
+		out.WriteString("}")
+	default:
+		out.WriteString(obj.Inspect())
+	}
+	return int64(out.Len()) <= limit
+}
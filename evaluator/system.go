@@ -0,0 +1,169 @@
+package evaluator
+
+import (
+	"io"
+	"math/rand"
+	"monkey/object"
+	"os"
+	"time"
+)
+
+var readFileBuiltin = &object.Builtin{
+	Capability: object.CapFSRead,
+	Fn: func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArgs("read_file", args, object.STRING_OBJ); err != nil {
+			return err
+		}
+		file, err := os.Open(args[0].(*object.String).Value)
+		if err != nil {
+			return newError(object.IOError, "`read_file` failed: %s", err)
+		}
+		defer file.Close()
+		// The size a file reports cannot be trusted: devices, pipes and /proc
+		// files report none, so the read itself is what must be bounded.
+		var in io.Reader = file
+		if rt := env.Runtime(); rt.MaxMemory > 0 {
+			in = io.LimitReader(file, rt.MaxMemory-rt.Allocated-stringSize(0)+1)
+		}
+		content, err := io.ReadAll(in)
+		if err != nil {
+			return newError(object.IOError, "`read_file` failed: %s", err)
+		}
+		return newString(env, string(content))
+	},
+}
+
+var readDirBuiltin = &object.Builtin{
+	Capability: object.CapFSRead,
+	Fn: func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArgs("read_dir", args, object.STRING_OBJ); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(args[0].(*object.String).Value)
+		if err != nil {
+			return newError(object.IOError, "`read_dir` failed: %s", err)
+		}
+		names := make([]string, len(entries))
+		for i, entry := range entries {
+			names[i] = entry.Name()
+		}
+		return stringArray(env, names)
+	},
+}
+
+var writeFileBuiltin = &object.Builtin{
+	Capability: object.CapFSWrite,
+	Fn: func(env *object.Environment, args ...object.Object) object.Object {
+		return writeFile("write_file", os.O_TRUNC, args)
+	},
+}
+
+var appendFileBuiltin = &object.Builtin{
+	Capability: object.CapFSWrite,
+	Fn: func(env *object.Environment, args ...object.Object) object.Object {
+		return writeFile("append_file", os.O_APPEND, args)
+	},
+}
+
+func writeFile(name string, mode int, args []object.Object) object.Object {
+	if err := checkArgs(name, args, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(args[0].(*object.String).Value, os.O_WRONLY|os.O_CREATE|mode, 0644)
+	if err != nil {
+		return newError(object.IOError, "`%s` failed: %s", name, err)
+	}
+	_, err = file.WriteString(args[1].(*object.String).Value)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return newError(object.IOError, "`%s` failed: %s", name, err)
+	}
+	return NULL
+}
+
+var getenvBuiltin = &object.Builtin{
+	Capability: object.CapEnv,
+	Fn: func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArgs("getenv", args, object.STRING_OBJ); err != nil {
+			return err
+		}
+		value, ok := os.LookupEnv(args[0].(*object.String).Value)
+		if !ok {
+			return NULL
+		}
+		return newString(env, value)
+	},
+}
+
+var nowBuiltin = &object.Builtin{
+	Capability: object.CapClock,
+	Fn: func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArgs("now", args); err != nil {
+			return err
+		}
+		return newInteger(time.Now().UnixMilli())
+	},
+}
+
+var sleepBuiltin = &object.Builtin{
+	Capability: object.CapClock,
+	Fn: func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArgs("sleep", args, object.INTEGER_OBJ); err != nil {
+			return err
+		}
+		timer := time.NewTimer(time.Duration(args[0].(*object.Integer).Value) * time.Millisecond)
+		defer timer.Stop()
+		var done <-chan struct{}
+		if ctx := env.Runtime().Context; ctx != nil {
+			done = ctx.Done()
+		}
+		select {
+		case <-timer.C:
+			return NULL
+		case <-done:
+			return checkContext(env.Runtime().Context)
+		}
+	},
+}
+
+var randBuiltin = &object.Builtin{
+	Capability: object.CapRandom,
+	Fn: func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArgs("rand", args, object.INTEGER_OBJ); err != nil {
+			return err
+		}
+		n := args[0].(*object.Integer).Value
+		if n <= 0 {
+			return newError(object.ValueError, "`rand` takes a positive bound, got %d", n)
+		}
+		return newInteger(rand.Int63n(n))
+	},
+}
+
+var argsBuiltin = &object.Builtin{
+	Capability: object.CapProcess,
+	Fn: func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArgs("args", args); err != nil {
+			return err
+		}
+		return stringArray(env, env.Runtime().Args)
+	},
+}
+
+var exitBuiltin = &object.Builtin{
+	Capability: object.CapProcess,
+	Fn: func(env *object.Environment, args ...object.Object) object.Object {
+		if err := checkArgs("exit", args, object.INTEGER_OBJ); err != nil {
+			return err
+		}
+		// Ending the process is left to whoever runs the program, so that
+		// exit stops a script without taking an embedding program with it.
+		code := args[0].(*object.Integer)
+		err := newError(object.ExitError, "exit with status %d", code.Value)
+		err.Details = map[string]object.Object{"code": code}
+		err.Fatal = true
+		return err
+	},
+}
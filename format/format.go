@@ -0,0 +1,378 @@
+// Package format writes Monkey programs out in one canonical style: a
+// statement to a line, blocks indented by four spaces and only the
+// parentheses the meaning needs. Comments are kept where they were, and
+// single blank lines between statements are kept too.
+package format
+
+import (
+	"bytes"
+	"errors"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+const indentation = "    "
+
+// Source formats src, failing if it does not parse.
+func Source(src []byte) ([]byte, error) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+
+	f := &formatter{lines: strings.Split(string(src), "\n"), comments: l.Comments()}
+	f.statements(program.Statements, token.Position{})
+	return f.out.Bytes(), nil
+}
+
+type formatter struct {
+	out      bytes.Buffer
+	indent   int
+	lines    []string
+	comments []lexer.Comment
+}
+
+// statements writes stmts a line each, with the comments that come before
+// end. An invalid end takes all the comments left.
+func (f *formatter) statements(stmts []ast.Statement, end token.Position) {
+	first := true
+	for i, stmt := range stmts {
+		f.commentsBefore(stmt.Pos(), &first)
+		if !first && f.blankBefore(stmt.Pos()) {
+			f.out.WriteString("\n")
+		}
+		first = false
+
+		f.writeIndent()
+		f.statement(stmt)
+		var next ast.Statement
+		if i+1 < len(stmts) {
+			next = stmts[i+1]
+		}
+		if needsSemicolon(stmt, next) {
+			f.out.WriteString(";")
+		}
+		f.out.WriteString("\n")
+	}
+	f.commentsBefore(end, &first)
+}
+
+// commentsBefore writes out the comments that come before pos. Trailing
+// comments stay at the end of the line written last; the others get lines
+// of their own.
+func (f *formatter) commentsBefore(pos token.Position, first *bool) {
+	for len(f.comments) > 0 && (!pos.IsValid() || before(f.comments[0].Pos, pos)) {
+		c := f.comments[0]
+		f.comments = f.comments[1:]
+
+		if c.Trailing && bytes.HasSuffix(f.out.Bytes(), []byte("\n")) {
+			f.out.Truncate(f.out.Len() - 1)
+			f.out.WriteString(" " + c.Text + "\n")
+			continue
+		}
+		if !*first && f.blankBefore(c.Pos) {
+			f.out.WriteString("\n")
+		}
+		*first = false
+		f.writeIndent()
+		f.out.WriteString(c.Text + "\n")
+	}
+}
+
+// blankBefore reports whether the source line before pos is blank.
+func (f *formatter) blankBefore(pos token.Position) bool {
+	i := pos.Line - 2
+	return i >= 0 && i < len(f.lines) && strings.TrimSpace(f.lines[i]) == ""
+}
+
+func before(a, b token.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+func (f *formatter) writeIndent() {
+	f.out.WriteString(strings.Repeat(indentation, f.indent))
+}
+
+// needsSemicolon reports whether stmt has to end in a semicolon when next
+// follows it. Only if and try expressions can do without, as they end in a
+// brace, and then only when next could not be read as carrying them on.
+func needsSemicolon(stmt, next ast.Statement) bool {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return true
+	}
+	switch es.Expression.(type) {
+	case *ast.IfExpression, *ast.TryExpression:
+	default:
+		return true
+	}
+	if next, ok := next.(*ast.ExpressionStatement); ok {
+		switch next.Token.Type {
+		case token.LPAREN, token.LBRACKET, token.MINUS:
+			return true
+		}
+	}
+	return false
+}
+
+func (f *formatter) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		f.out.WriteString("let " + stmt.Name.Value + " = ")
+		f.expression(stmt.Value)
+	case *ast.ReturnStatement:
+		f.out.WriteString("return ")
+		f.expression(stmt.ReturnValue)
+	case *ast.ThrowStatement:
+		f.out.WriteString("throw ")
+		f.expression(stmt.Value)
+	case *ast.ExpressionStatement:
+		f.expression(stmt.Expression)
+	}
+}
+
+func (f *formatter) block(block *ast.BlockStatement) {
+	if len(block.Statements) == 0 && (len(f.comments) == 0 || !before(f.comments[0].Pos, block.End)) {
+		f.out.WriteString("{}")
+		return
+	}
+	f.out.WriteString("{\n")
+	f.indent++
+	f.statements(block.Statements, block.End)
+	f.indent--
+	f.writeIndent()
+	f.out.WriteString("}")
+}
+
+func (f *formatter) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		f.out.WriteString(exp.Value)
+	case *ast.IntegerLiteral, *ast.Boolean:
+		f.out.WriteString(exp.TokenLiteral())
+	case *ast.StringLiteral, *ast.TemplateLiteral:
+		f.out.WriteString(`"` + exp.TokenLiteral() + `"`)
+	case *ast.PrefixExpression:
+		f.out.WriteString(exp.Operator)
+		f.operand(exp.Right, precedence(exp.Right) <= parser.PREFIX)
+	case *ast.InfixExpression:
+		prec := parser.Precedence(exp.Token.Type)
+		f.operand(exp.Left, precedence(exp.Left) < prec)
+		f.out.WriteString(" " + exp.Operator + " ")
+		f.operand(exp.Right, precedence(exp.Right) <= prec)
+	case *ast.CallExpression:
+		f.operand(exp.Function, precedence(exp.Function) < parser.CALL)
+		f.list("(", ")", exp.Arguments, exp.End)
+	case *ast.IndexExpression:
+		f.operand(exp.Left, precedence(exp.Left) < parser.CALL)
+		f.out.WriteString("[")
+		f.expression(exp.Index)
+		f.out.WriteString("]")
+	case *ast.SliceExpression:
+		f.operand(exp.Left, precedence(exp.Left) < parser.CALL)
+		f.out.WriteString("[")
+		if exp.Start != nil {
+			f.expression(exp.Start)
+		}
+		f.out.WriteString(":")
+		if exp.End != nil {
+			f.expression(exp.End)
+		}
+		f.out.WriteString("]")
+	case *ast.ArrayLiteral:
+		f.list("[", "]", exp.Elements, exp.End)
+	case *ast.HashLiteral:
+		f.hash(exp)
+	case *ast.FunctionLiteral:
+		f.out.WriteString("fn(")
+		for i, param := range exp.Parameters {
+			if i > 0 {
+				f.out.WriteString(", ")
+			}
+			f.out.WriteString(param.Value)
+		}
+		f.out.WriteString(") ")
+		f.block(exp.Body)
+	case *ast.IfExpression:
+		f.out.WriteString("if (")
+		f.expression(exp.Condition)
+		f.out.WriteString(") ")
+		f.block(exp.Consequence)
+		if exp.Alternative != nil {
+			f.out.WriteString(" else ")
+			f.block(exp.Alternative)
+		}
+	case *ast.TryExpression:
+		f.out.WriteString("try ")
+		f.block(exp.Block)
+		if exp.Catch != nil {
+			f.out.WriteString(" catch (" + exp.Parameter.Value + ") ")
+			f.block(exp.Catch)
+		}
+		if exp.Finally != nil {
+			f.out.WriteString(" finally ")
+			f.block(exp.Finally)
+		}
+	}
+}
+
+func (f *formatter) operand(exp ast.Expression, parens bool) {
+	if parens {
+		f.out.WriteString("(")
+	}
+	f.expression(exp)
+	if parens {
+		f.out.WriteString(")")
+	}
+}
+
+// list writes exps between open and close, which is at end in the source.
+func (f *formatter) list(open, close string, exps []ast.Expression, end token.Position) {
+	f.items(open, close, exps, exps, end, func(i int) {
+		f.expression(exps[i])
+	})
+}
+
+// hash writes the pairs of hash in the order they appear in the source, as
+// the literal itself does not keep it.
+func (f *formatter) hash(hash *ast.HashLiteral) {
+	keys := make([]ast.Expression, 0, len(hash.Pairs))
+	for key := range hash.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return before(keys[i].Pos(), keys[j].Pos())
+	})
+	values := make([]ast.Expression, len(keys))
+	for i, key := range keys {
+		values[i] = hash.Pairs[key]
+	}
+
+	f.items("{", "}", keys, values, hash.End, func(i int) {
+		f.expression(keys[i])
+		f.out.WriteString(": ")
+		f.expression(values[i])
+	})
+}
+
+// items writes comma-separated items between open and close, which is at
+// end in the source, calling item to write the i'th, which runs from
+// firsts[i] to lasts[i]. They go on one line unless there are comments
+// between them; then each item gets a line of its own, so that the comments
+// stay beside it.
+func (f *formatter) items(open, close string, firsts, lasts []ast.Expression, end token.Position, item func(i int)) {
+	f.out.WriteString(open)
+	if !f.commentsBetween(firsts, lasts, end) {
+		for i := range firsts {
+			if i > 0 {
+				f.out.WriteString(", ")
+			}
+			item(i)
+		}
+		f.out.WriteString(close)
+		return
+	}
+
+	f.out.WriteString("\n")
+	f.indent++
+	first := true
+	for i, exp := range firsts {
+		f.commentsBefore(exp.Pos(), &first)
+		first = false
+		f.writeIndent()
+		item(i)
+		if i+1 < len(firsts) {
+			f.out.WriteString(",")
+		}
+		f.out.WriteString("\n")
+	}
+	f.commentsBefore(end, &first)
+	f.indent--
+	f.writeIndent()
+	f.out.WriteString(close)
+}
+
+// commentsBetween reports whether any comment before end lies outside the
+// items running from firsts[i] to lasts[i], rather than inside one of them.
+func (f *formatter) commentsBetween(firsts, lasts []ast.Expression, end token.Position) bool {
+	i := 0
+	for _, c := range f.comments {
+		if !before(c.Pos, end) {
+			return false
+		}
+		for i < len(lasts) && before(lastPos(lasts[i]), c.Pos) {
+			i++
+		}
+		if i == len(firsts) || before(c.Pos, firsts[i].Pos()) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastPos is where the last token of exp starts, or one close enough before
+// it that no comment can come between them.
+func lastPos(exp ast.Expression) token.Position {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		return lastPos(exp.Right)
+	case *ast.InfixExpression:
+		return lastPos(exp.Right)
+	case *ast.CallExpression:
+		return exp.End
+	case *ast.ArrayLiteral:
+		return exp.End
+	case *ast.HashLiteral:
+		return exp.End
+	case *ast.IndexExpression:
+		return lastPos(exp.Index)
+	case *ast.SliceExpression:
+		if exp.End != nil {
+			return lastPos(exp.End)
+		}
+		if exp.Start != nil {
+			return lastPos(exp.Start)
+		}
+	case *ast.FunctionLiteral:
+		return exp.Body.End
+	case *ast.IfExpression:
+		if exp.Alternative != nil {
+			return exp.Alternative.End
+		}
+		return exp.Consequence.End
+	case *ast.TryExpression:
+		if exp.Finally != nil {
+			return exp.Finally.End
+		}
+		if exp.Catch != nil {
+			return exp.Catch.End
+		}
+		return exp.Block.End
+	}
+	return exp.Pos()
+}
+
+// precedence is how tightly exp holds together, compared with the operators
+// around it. Expressions ending in a block count as loosest of all, so they
+// get parentheses wherever they are an operand.
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.FunctionLiteral, *ast.IfExpression, *ast.TryExpression:
+		return parser.LOWEST
+	case *ast.InfixExpression:
+		return parser.Precedence(exp.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression, *ast.SliceExpression:
+		return parser.INDEX
+	}
+	return parser.INDEX + 1
+}
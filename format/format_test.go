@@ -0,0 +1,68 @@
+package format
+
+import "testing"
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5", "let x = 5;\n"},
+		{"let add = fn(x,y){x+y}; add(1,2)", "let add = fn(x, y) {\n    x + y;\n};\nadd(1, 2);\n"},
+		{"(1 + 2) * 3; 1 + (2 * 3); 1 - (2 - 3); (1 - 2) - 3", "(1 + 2) * 3;\n1 + 2 * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n"},
+		{"-(1 + 2); -(-x); !(a == b); (a < b) == (c > d)", "-(1 + 2);\n-(-x);\n!(a == b);\na < b == c > d;\n"},
+		{"(-a)[0]; -a[0]; f(x)[0]; (f)(x)(y); (fn(x) { x })(1)", "(-a)[0];\n-a[0];\nf(x)[0];\nf(x)(y);\n(fn(x) {\n    x;\n})(1);\n"},
+		{"a[1:]; a[:2]; a[:]", "a[1:];\na[:2];\na[:];\n"},
+		{`{"b": [1, 2], "a": {}}`, "{\"b\": [1, 2], \"a\": {}};\n"},
+		{`"hi ${name}"; "x"`, "\"hi ${name}\";\n\"x\";\n"},
+		{"if (x) { 1 } else { 2 }; let y = 1", "if (x) {\n    1;\n} else {\n    2;\n}\nlet y = 1;\n"},
+		{"if (x) { 1 }; -1", "if (x) {\n    1;\n};\n-1;\n"},
+		{"try { f() } catch (e) { throw e } finally { g() }", "try {\n    f();\n} catch (e) {\n    throw e;\n} finally {\n    g();\n}\n"},
+		{"let f = fn() {}; fn() { return 1 }", "let f = fn() {};\nfn() {\n    return 1;\n};\n"},
+		{"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+		{
+			"// Header.\n\nlet a = 1; // one\n// Before b.\nlet b = fn() { // opens\n  // inside\n  b;\n\n  // last\n};\n// The end.\n",
+			"// Header.\n\nlet a = 1; // one\n// Before b.\nlet b = fn() { // opens\n    // inside\n    b;\n\n    // last\n};\n// The end.\n",
+		},
+		{"let e = fn() {\n  // nothing yet\n}", "let e = fn() {\n    // nothing yet\n};\n"},
+		{"let a = [1, // first\n  2];\nlet b = 3;", "let a = [\n    1, // first\n    2\n];\nlet b = 3;\n"},
+		{
+			"let xs = [\n  1, // one\n  2, // two\n  3\n];",
+			"let xs = [\n    1, // one\n    2, // two\n    3\n];\n",
+		},
+		{
+			"let h = { // settings\n  // The name.\n  \"name\": \"x\",\n  \"size\": 2 // in bytes\n};\nf(a, // first\n  b\n  // nothing after b\n)",
+			"let h = { // settings\n    // The name.\n    \"name\": \"x\",\n    \"size\": 2 // in bytes\n};\nf(\n    a, // first\n    b\n    // nothing after b\n);\n",
+		},
+		{"let a = [1, 2]; // two\nf([3], 4) // call", "let a = [1, 2]; // two\nf([3], 4); // call\n"},
+		{
+			"f([1, // c\n 2], {\"a\": [fn() { x // y\n }]})",
+			"f([\n    1, // c\n    2\n], {\"a\": [fn() {\n    x; // y\n}]});\n",
+		},
+		{"// only a comment", "// only a comment\n"},
+		{"", ""},
+	}
+
+	for i, tt := range tests {
+		got, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("tests[%d] unexpected error: %s", i, err)
+			continue
+		}
+		if string(got) != tt.expected {
+			t.Errorf("tests[%d] wrong output, expected=\n%s\ngot=\n%s", i, tt.expected, got)
+			continue
+		}
+
+		again, err := Source(got)
+		if err != nil || string(again) != string(got) {
+			t.Errorf("tests[%d] not idempotent, formatting again gave=\n%s\nerr=%v", i, again, err)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	if _, err := Source([]byte("let = 5;")); err == nil {
+		t.Fatalf("expected an error for a program that does not parse")
+	}
+}
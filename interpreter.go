@@ -0,0 +1,175 @@
+// Package monkey embeds the Monkey interpreter in Go programs.
+//
+// Each Interpreter owns its global environment, I/O streams and builtins, so
+// several interpreters can run side by side in one process without seeing
+// each other's bindings.
+package monkey
+
+import (
+	"context"
+	"io"
+	"monkey/ast"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/resolver"
+	"os"
+)
+
+type Interpreter struct {
+	env *object.Environment
+}
+
+type Option func(*Interpreter)
+
+func WithStdout(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().Stdout = w
+	}
+}
+
+func WithStderr(w io.Writer) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().Stderr = w
+	}
+}
+
+func WithStdin(r io.Reader) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().SetInput(r)
+	}
+}
+
+// WithArgs sets the command line arguments returned by the args builtin.
+func WithArgs(args ...string) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().Args = args
+	}
+}
+
+// WithCapabilities grants scripts only the given capabilities, replacing the
+// default of granting all of them. Builtins needing any other capability
+// fail with a permission error when called.
+func WithCapabilities(caps ...object.Capability) Option {
+	return func(i *Interpreter) {
+		granted := object.NoCapabilities
+		for _, c := range caps {
+			granted |= c
+		}
+		i.env.Runtime().Capabilities = granted
+	}
+}
+
+func WithBuiltin(name string, fn object.BuiltinFunction) Option {
+	return func(i *Interpreter) {
+		i.RegisterBuiltin(name, fn)
+	}
+}
+
+// WithMaxSteps limits how many nodes a single Eval or Call may evaluate.
+func WithMaxSteps(n int) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().MaxSteps = n
+	}
+}
+
+// WithMaxDepth limits how deeply function calls may nest; zero removes the
+// limit, which risks crashing the process on runaway recursion.
+func WithMaxDepth(n int) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().MaxDepth = n
+	}
+}
+
+// WithMaxMemory limits how many bytes a single Eval or Call may allocate for
+// strings, arrays and hashes.
+func WithMaxMemory(bytes int64) Option {
+	return func(i *Interpreter) {
+		i.env.Runtime().MaxMemory = bytes
+	}
+}
+
+func NewInterpreter(opts ...Option) *Interpreter {
+	i := &Interpreter{env: object.NewEnvironment()}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// RegisterBuiltin makes fn callable as name from scripts run by this
+// interpreter. It takes precedence over a standard builtin of the same name.
+// A nil result from fn is null.
+func (i *Interpreter) RegisterBuiltin(name string, fn object.BuiltinFunction) {
+	i.env.Runtime().Builtins[name] = &object.Builtin{Name: name, Fn: fn}
+}
+
+func (i *Interpreter) Environment() *object.Environment {
+	return i.env
+}
+
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	return i.env.Get(name)
+}
+
+func (i *Interpreter) Set(name string, value object.Object) {
+	i.env.Set(name, value)
+}
+
+// Eval parses and evaluates source in the interpreter's global environment,
+// giving up once ctx is done. Parse failures are reported as a *ParseError
+// and Monkey errors, including exceeded limits, as a *RuntimeError; in both
+// cases the returned object is nil.
+func (i *Interpreter) Eval(ctx context.Context, source string) (object.Object, error) {
+	return i.eval(ctx, lexer.New(source))
+}
+
+// EvalFile evaluates the file at path like Eval, naming it in the positions
+// of stack traces.
+func (i *Interpreter) EvalFile(ctx context.Context, path string) (object.Object, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return i.eval(ctx, lexer.NewFile(path, string(source)))
+}
+
+func (i *Interpreter) eval(ctx context.Context, l *lexer.Lexer) (object.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors(), Diagnostics: p.Diagnostics()}
+	}
+	resolver.Resolve(program)
+	return result(evaluator.EvalContext(ctx, program, i.env))
+}
+
+// Call looks up name as a script would and applies it to args.
+func (i *Interpreter) Call(name string, args ...object.Object) (object.Object, error) {
+	return i.CallContext(context.Background(), name, args...)
+}
+
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...object.Object) (object.Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	fn := evaluator.Eval(&ast.Identifier{Value: name}, i.env)
+	if err, ok := fn.(*object.Error); ok {
+		return nil, &RuntimeError{Err: err}
+	}
+	return result(evaluator.ApplyContext(ctx, fn, args, i.env))
+}
+
+func result(obj object.Object) (object.Object, error) {
+	if err, ok := obj.(*object.Error); ok {
+		return nil, &RuntimeError{Err: err}
+	}
+	if obj == nil {
+		return evaluator.NULL, nil
+	}
+	return obj, nil
+}
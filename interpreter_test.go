@@ -0,0 +1,274 @@
+package monkey
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInterpreterEval(t *testing.T) {
+	var out bytes.Buffer
+	interpreter := NewInterpreter(WithStdout(&out))
+
+	result, err := interpreter.Eval(context.Background(), `let add = fn(a, b) { a + b }; puts("hi"); add(1, 2)`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	integer, ok := result.(*object.Integer)
+	if !ok || integer.Value != 3 {
+		t.Fatalf("wrong result, got=%s", result.Inspect())
+	}
+	if out.String() != "hi\n" {
+		t.Fatalf("wrong output, got=%q", out.String())
+	}
+
+	result, err = interpreter.Call("add", &object.Integer{Value: 4}, &object.Integer{Value: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if integer, ok := result.(*object.Integer); !ok || integer.Value != 9 {
+		t.Fatalf("wrong result from Call, got=%s", result.Inspect())
+	}
+}
+
+func TestInterpreterErrors(t *testing.T) {
+	interpreter := NewInterpreter()
+
+	_, err := interpreter.Eval(context.Background(), "let = 5;")
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected a *ParseError, got=%T (%v)", err, err)
+	}
+	if len(parseErr.Errors) == 0 {
+		t.Fatalf("parse error has no messages")
+	}
+	if len(parseErr.Diagnostics) != len(parseErr.Errors) || parseErr.Diagnostics[0].Pos().Column != 5 {
+		t.Fatalf("wrong diagnostics, got=%v", parseErr.Diagnostics)
+	}
+
+	_, err = interpreter.Eval(context.Background(), "1 + true")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a *RuntimeError, got=%T (%v)", err, err)
+	}
+	if runtimeErr.Error() != "type mismatch: INTEGER + BOOLEAN" {
+		t.Fatalf("wrong message, got=%q", runtimeErr.Error())
+	}
+	if d := runtimeErr.Diagnostic(); d.String() != "1:1: type mismatch: INTEGER + BOOLEAN (TypeError)" {
+		t.Fatalf("wrong diagnostic, got=%q", d.String())
+	}
+	var objErr *object.Error
+	if !errors.As(err, &objErr) || objErr.Kind != object.TypeError {
+		t.Fatalf("expected to unwrap a TypeError, got=%v", err)
+	}
+	if left := objErr.Details["left"]; left == nil || left.Inspect() != "INTEGER" {
+		t.Fatalf("wrong details, got=%v", objErr.Details)
+	}
+
+	_, err = interpreter.Eval(context.Background(), `throw error("ConfigError", "missing key")`)
+	if !errors.As(err, &runtimeErr) || runtimeErr.Kind() != "ConfigError" {
+		t.Fatalf("expected a ConfigError, got=%v", err)
+	}
+
+	if _, err := interpreter.Call("missing"); !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a *RuntimeError calling an unknown name, got=%v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := interpreter.Eval(ctx, "1"); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got=%v", err)
+	}
+}
+
+func TestInterpretersAreIsolated(t *testing.T) {
+	double := func(env *object.Environment, args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	}
+	first := NewInterpreter(WithBuiltin("double", double))
+	second := NewInterpreter()
+
+	if _, err := first.Eval(context.Background(), "let x = double(21);"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if x, ok := first.Get("x"); !ok || x.(*object.Integer).Value != 42 {
+		t.Fatalf("x not bound in first interpreter")
+	}
+	if _, ok := second.Get("x"); ok {
+		t.Fatalf("x leaked into second interpreter")
+	}
+	if _, err := second.Eval(context.Background(), "double(1)"); err == nil {
+		t.Fatalf("builtin leaked into second interpreter")
+	}
+}
+
+func TestInterpreterEvalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.mk")
+	if err := os.WriteFile(path, []byte(`let greeting = "hello"; greeting`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	interpreter := NewInterpreter()
+	result, err := interpreter.EvalFile(context.Background(), path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "hello" {
+		t.Fatalf("wrong result, got=%s", result.Inspect())
+	}
+}
+
+func TestInterpreterStackTrace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "math.mk")
+	source := "let add = fn(a, b) {\n  a + b\n};\nlet twice = fn(x) { add(x, x) * 2 };\ntwice(true);\n"
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewInterpreter().EvalFile(context.Background(), path)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a runtime error, got=%v", err)
+	}
+	expected := fmt.Sprintf("at add (%[1]s:2:3)\nat twice (%[1]s:4:21)\nat <main> (%[1]s:5:1)", path)
+	if runtimeErr.StackTrace() != expected {
+		t.Fatalf("wrong stack trace. expected=%q, got=%q", expected, runtimeErr.StackTrace())
+	}
+}
+
+func TestInterpreterExit(t *testing.T) {
+	_, err := NewInterpreter().Eval(context.Background(), "try { exit(3) } catch (e) { 0 } finally { puts(1) }")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a RuntimeError, got=%v", err)
+	}
+	if code, ok := runtimeErr.ExitCode(); !ok || code != 3 {
+		t.Fatalf("wrong exit code, expected=3, got=%d (%t)", code, ok)
+	}
+
+	_, err = NewInterpreter().Eval(context.Background(), "1 + true")
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a RuntimeError, got=%v", err)
+	}
+	if _, ok := runtimeErr.ExitCode(); ok {
+		t.Fatalf("expected no exit code for %s", runtimeErr)
+	}
+}
+
+func TestInterpreterLimits(t *testing.T) {
+	tests := []struct {
+		options  []Option
+		timeout  time.Duration
+		input    string
+		expected string
+	}{
+		{nil, 0, "let f = fn() { 1 + f() }; f()", "stack overflow"},
+		{[]Option{WithMaxDepth(10)}, 0, "let f = fn(n) { if (n > 0) { 1 + f(n - 1) } else { 0 } }; f(20)", "stack overflow"},
+		{[]Option{WithMaxDepth(10)}, 0, "let f = fn(n) { if (n > 0) { 1 + f(n - 1) } else { 0 } }; f(5)", ""},
+		{[]Option{WithMaxDepth(10)}, 0, "let f = fn(n) { if (n > 0) { f(n - 1) } else { 0 } }; f(20)", ""},
+		{nil, 0, "let f = fn(n) { if (n > 0) { f(n - 1) } else { 0 } }; f(100000)", ""},
+		{[]Option{WithMaxSteps(10000)}, 0, "let f = fn() { f() }; f()", "step limit exceeded"},
+		{nil, 50 * time.Millisecond, "let f = fn() { f() }; f()", "execution timed out"},
+		{[]Option{WithMaxSteps(100)}, 0, "let f = fn(n) { if (n > 0) { f(n - 1) } else { 0 } }; f(1000)", "step limit exceeded"},
+		{[]Option{WithMaxMemory(1 << 20)}, 0, `let grow = fn(s) { grow(s + s) }; grow("x")`, "memory limit exceeded"},
+		{[]Option{WithMaxMemory(1 << 20)}, 0, `repeat("x", 10000000)`, "memory limit exceeded"},
+		{[]Option{WithMaxMemory(1 << 20)}, 0, `let drain = fn(a) { if (len(a) > 0) { drain(rest(a)) } }; drain(split(repeat("a,", 5000), ","))`, ""},
+		{[]Option{WithMaxMemory(1 << 20)}, 0, `let grow = fn(a) { grow(push(a, len(a))) }; grow([])`, "memory limit exceeded"},
+		{[]Option{WithMaxMemory(1 << 20)}, 0, `len(split(repeat("a,", 1000), ","))`, ""},
+		{[]Option{WithMaxMemory(1 << 20)}, 0, `read_file("/dev/zero")`, "memory limit exceeded"},
+		{[]Option{WithMaxMemory(1 << 20)}, 0, `let nest = fn(a, n) { if (n > 0) { nest([a, a], n - 1) } else { a } }; let x = nest([1], 22); "${x}"`, "memory limit exceeded"},
+		{[]Option{WithMaxMemory(1 << 20)}, 0, `let nest = fn(a, n) { if (n > 0) { nest({"l": a, "r": a}, n - 1) } else { a } }; format("%s", nest([1], 22))`, "memory limit exceeded"},
+		{[]Option{WithMaxMemory(1 << 20)}, 0, `let nest = fn(a, n) { if (n > 0) { nest([a, a], n - 1) } else { a } }; "${nest([1], 4)}"`, ""},
+		{[]Option{WithMaxDepth(0)}, 50 * time.Millisecond, "let f = fn(n) { if (n > 0) { f(n - 1) + f(n - 1) } else { 0 } }; f(100)", "execution timed out"},
+		{nil, 0, "let f = fn() { 1 + f() }; try { f() } catch (e) { 0 } finally { 0 }", "stack overflow"},
+		{[]Option{WithMaxSteps(100)}, 0, "let f = fn() { f() }; try { f() } catch (e) { 0 }", "step limit exceeded"},
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+			defer cancel()
+		}
+		_, err := NewInterpreter(tt.options...).Eval(ctx, tt.input)
+		if tt.expected == "" {
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %s", tt.input, err)
+			}
+			continue
+		}
+		var runtimeErr *RuntimeError
+		if !errors.As(err, &runtimeErr) || runtimeErr.Error() != tt.expected {
+			t.Fatalf("%s: expected %q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+
+	interpreter := NewInterpreter(WithMaxSteps(200))
+	if _, err := interpreter.Eval(context.Background(), "let loop = fn(n) { if (n > 0) { loop(n - 1) } }; loop(3)"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := interpreter.Call("loop", &object.Integer{Value: 3}); err != nil {
+		t.Fatalf("step budget should start again for each call, got=%s", err)
+	}
+	if _, err := interpreter.Call("loop", &object.Integer{Value: 100}); err == nil {
+		t.Fatalf("expected Call to respect the step budget")
+	}
+}
+
+func TestInterpreterCapabilities(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	var out bytes.Buffer
+	interpreter := NewInterpreter(
+		WithStdout(&out),
+		WithArgs("a", "b"),
+		WithCapabilities(object.CapStdout, object.CapFSWrite, object.CapProcess),
+	)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`puts("ok")`, "null"},
+		{`write_file("` + path + `", "one"); append_file("` + path + `", "two")`, "null"},
+		{`args()`, "[a, b]"},
+		{`read_file("` + path + `")`, "permission denied: `read_file` requires the fs-read capability"},
+		{`getenv("HOME")`, "permission denied: `getenv` requires the env capability"},
+		{`now()`, "permission denied: `now` requires the clock capability"},
+		{`rand(10)`, "permission denied: `rand` requires the random capability"},
+		{`gets()`, "permission denied: `gets` requires the stdin capability"},
+		{`read_line()`, "permission denied: `read_line` requires the stdin capability"},
+		{`len("still allowed")`, "13"},
+	}
+
+	for _, tt := range tests {
+		result, err := interpreter.Eval(context.Background(), tt.input)
+		got := fmt.Sprint(err)
+		if err == nil {
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Fatalf("%s: expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) != "onetwo" {
+		t.Fatalf("file not written, got=%q (%v)", content, err)
+	}
+	if out.String() != "ok\n" {
+		t.Fatalf("wrong output, got=%q", out.String())
+	}
+
+	result, err := NewInterpreter().Eval(context.Background(), `read_file("`+path+`")`)
+	if err != nil || result.Inspect() != "onetwo" {
+		t.Fatalf("default interpreter should grant all capabilities, got=%v", err)
+	}
+}
@@ -1,24 +1,65 @@
 package lexer
 
-import "monkey/token"
+import (
+	"fmt"
+	"monkey/token"
+	"strings"
+)
 
 type Lexer struct {
 	input             string
 	currentPosition   int
 	readAheadPosition int
 	currentChar       byte
+	pos               token.Position
+	unterminated      bool
+	comments          []Comment
+	tokenLine         int
+}
+
+// Comment is a // comment, which the lexer skips like whitespace but keeps
+// for tools that write the source back out. A Trailing comment follows a
+// token on the same line.
+type Comment struct {
+	Pos      token.Position
+	Text     string
+	Trailing bool
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewAt(input, token.Position{Line: 1, Column: 1})
+}
+
+// NewFile returns a lexer whose token positions name filename.
+func NewFile(filename, input string) *Lexer {
+	return NewAt(input, token.Position{Filename: filename, Line: 1, Column: 1})
+}
+
+// NewAt returns a lexer for input that starts at pos in some larger source,
+// such as the text of a placeholder inside a string.
+func NewAt(input string, pos token.Position) *Lexer {
+	l := &Lexer{input: input, pos: pos}
+	l.pos.Column--
 	l.readChar()
 	return l
 }
 
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	l.skipWhitespace()
+	pos := l.pos
+	tok := l.nextToken()
+	tok.Pos = pos
+	l.tokenLine = l.pos.Line
+	return tok
+}
+
+// Comments returns the comments read so far, in order.
+func (l *Lexer) Comments() []Comment {
+	return l.comments
+}
+
+func (l *Lexer) nextToken() token.Token {
+	var tok token.Token
 
 	switch l.currentChar {
 	case '=':
@@ -63,7 +104,11 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.SEMICOLON, l.currentChar)
 	case '"':
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		literal, template := l.readString()
+		if template {
+			tok.Type = token.TEMPLATE
+		}
+		tok.Literal = literal
 	case '[':
 		tok = newToken(token.LBRACKET, l.currentChar)
 	case ']':
@@ -104,9 +149,26 @@ func isDigit(ch byte) bool {
 }
 
 func (l *Lexer) skipWhitespace() {
-	for l.currentChar == ' ' || l.currentChar == '\t' || l.currentChar == '\n' || l.currentChar == '\r' {
+	for {
+		switch {
+		case l.currentChar == ' ' || l.currentChar == '\t' || l.currentChar == '\n' || l.currentChar == '\r':
+			l.readChar()
+		case l.currentChar == '/' && l.peekChar() == '/':
+			l.readComment()
+		default:
+			return
+		}
+	}
+}
+
+func (l *Lexer) readComment() {
+	comment := Comment{Pos: l.pos, Trailing: l.tokenLine == l.pos.Line}
+	position := l.currentPosition
+	for l.currentChar != '\n' && l.currentChar != 0 {
 		l.readChar()
 	}
+	comment.Text = strings.TrimRight(l.input[position:l.currentPosition], " \t\r")
+	l.comments = append(l.comments, comment)
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -122,6 +184,11 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 }
 
 func (l *Lexer) readChar() {
+	if l.currentChar == '\n' {
+		l.pos.Line++
+		l.pos.Column = 0
+	}
+	l.pos.Column++
 	if l.readAheadPosition >= len(l.input) {
 		l.currentChar = 0
 	} else {
@@ -143,13 +210,93 @@ func (l *Lexer) peekChar() byte {
 	}
 }
 
-func (l *Lexer) readString() string {
+// readString reads up to the closing quote and reports whether the string
+// contains ${...} placeholders. Quotes and braces inside a placeholder belong
+// to the embedded expression, so they do not end the string.
+func (l *Lexer) readString() (string, bool) {
 	position := l.currentPosition + 1
+	template := false
 	for {
 		l.readChar()
-		if l.currentChar == '"' || l.currentChar == 0 {
+		if l.currentChar == '$' && l.peekChar() == '{' {
+			template = true
+			l.readChar()
+			l.skipPlaceholder()
+		}
+		if l.currentChar == 0 {
+			l.unterminated = true
+			break
+		}
+		if l.currentChar == '"' {
 			break
 		}
 	}
-	return l.input[position:l.currentPosition]
+	return l.input[position:l.currentPosition], template
+}
+
+// Incomplete reports whether input stops inside brackets or a string, so
+// that more lines are needed to finish it.
+func Incomplete(input string) bool {
+	l := New(input)
+	depth := 0
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+	}
+	return depth > 0 || l.unterminated
+}
+
+func (l *Lexer) skipPlaceholder() {
+	depth := 1
+	for depth > 0 {
+		l.readChar()
+		switch l.currentChar {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case '"':
+			l.readString()
+			if l.currentChar == 0 {
+				return
+			}
+		case 0:
+			return
+		}
+	}
+}
+
+// Placeholder is the source of one ${...} in a TEMPLATE token, with its byte
+// offset inside the token's literal.
+type Placeholder struct {
+	Source string
+	Offset int
+}
+
+// SplitTemplate breaks the literal of a TEMPLATE token into the text around
+// each ${...} placeholder and the placeholders themselves, so that
+// len(texts) == len(placeholders)+1.
+func SplitTemplate(literal string) (texts []string, placeholders []Placeholder, err error) {
+	l := New(literal)
+	start := 0
+	for l.currentChar != 0 {
+		if l.currentChar == '$' && l.peekChar() == '{' {
+			texts = append(texts, literal[start:l.currentPosition])
+			l.readChar()
+			sourceStart := l.currentPosition + 1
+			l.skipPlaceholder()
+			if l.currentChar != '}' {
+				return nil, nil, fmt.Errorf("unterminated placeholder in string %q", literal)
+			}
+			placeholders = append(placeholders, Placeholder{Source: literal[sourceStart:l.currentPosition], Offset: sourceStart})
+			start = l.currentPosition + 1
+		}
+		l.readChar()
+	}
+	texts = append(texts, literal[start:])
+	return texts, placeholders, nil
 }
@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"monkey/token"
+	"strings"
 	"testing"
 )
 
@@ -134,3 +135,148 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTemplateStrings(t *testing.T) {
+	input := `"plain" "hi ${name}" "${ {"a": "}"}["a"] }!" "${"nested ${x}"}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING, "plain"},
+		{token.TEMPLATE, "hi ${name}"},
+		{token.TEMPLATE, `${ {"a": "}"}["a"] }!`},
+		{token.TEMPLATE, `${"nested ${x}"}`},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for index, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong, expected=%q, got=%q", index, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", index, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestSplitTemplate(t *testing.T) {
+	texts, placeholders, err := SplitTemplate(`hello ${user["name"]}, you have ${len(items)} items`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expectedTexts := []string{"hello ", ", you have ", " items"}
+	expectedPlaceholders := []Placeholder{{`user["name"]`, 8}, {"len(items)", 34}}
+	if len(texts) != len(expectedTexts) || len(placeholders) != len(expectedPlaceholders) {
+		t.Fatalf("wrong number of parts, got texts=%q placeholders=%v", texts, placeholders)
+	}
+	for i := range expectedTexts {
+		if texts[i] != expectedTexts[i] {
+			t.Fatalf("texts[%d] wrong, expected=%q, got=%q", i, expectedTexts[i], texts[i])
+		}
+	}
+	for i := range expectedPlaceholders {
+		if placeholders[i] != expectedPlaceholders[i] {
+			t.Fatalf("placeholders[%d] wrong, expected=%v, got=%v", i, expectedPlaceholders[i], placeholders[i])
+		}
+	}
+
+	if _, _, err := SplitTemplate("oops ${1 + "); err == nil {
+		t.Fatalf("expected an error for an unterminated placeholder")
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  add(x,\n\t\"é\" + y)"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"add", 2, 3},
+		{"(", 2, 6},
+		{"x", 2, 7},
+		{",", 2, 8},
+		{"é", 3, 2},
+		{"+", 3, 7},
+		{"y", 3, 9},
+		{")", 3, 10},
+		{"", 3, 11},
+	}
+
+	l := NewFile("test.mk", input)
+
+	for index, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong, expected=%q, got=%q", index, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn || tok.Pos.Filename != "test.mk" {
+			t.Fatalf("tests[%d] - position wrong, expected=test.mk:%d:%d, got=%s", index, tt.expectedLine, tt.expectedColumn, tok.Pos)
+		}
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;", false},
+		{"", false},
+		{"let add = fn(x, y) {", true},
+		{"let add = fn(x, y) {\n  x + y\n}", false},
+		{"add(1,", true},
+		{"[1, [2, 3]", true},
+		{`{"a": 1`, true},
+		{`"hello`, true},
+		{"\"hello\nworld\"", false},
+		{`"${len("a`, true},
+		{`"${1 + 2}"`, false},
+		{"1 + 2)", false},
+	}
+
+	for i, tt := range tests {
+		if got := Incomplete(tt.input); got != tt.expected {
+			t.Errorf("tests[%d] Incomplete(%q) wrong, expected=%t, got=%t", i, tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// header\nlet x = 5; // five\n\t// indented\nx / 2 // half"
+
+	l := New(input)
+	var literals []string
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		literals = append(literals, tok.Literal)
+	}
+	if strings.Join(literals, " ") != "let x = 5 ; x / 2" {
+		t.Fatalf("comments were not skipped, got=%q", literals)
+	}
+
+	expected := []Comment{
+		{token.Position{Line: 1, Column: 1}, "// header", false},
+		{token.Position{Line: 2, Column: 12}, "// five", true},
+		{token.Position{Line: 3, Column: 2}, "// indented", false},
+		{token.Position{Line: 4, Column: 7}, "// half", true},
+	}
+	comments := l.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("wrong number of comments, got=%v", comments)
+	}
+	for i, c := range expected {
+		if comments[i] != c {
+			t.Errorf("comments[%d] wrong, expected=%+v, got=%+v", i, c, comments[i])
+		}
+	}
+}
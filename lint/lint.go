@@ -0,0 +1,472 @@
+// Package lint looks through Monkey programs for mistakes the parser lets
+// through: names bound and never used, names that hide others, code that
+// can never run, calls that cannot work and comparisons that cannot vary.
+//
+// Each problem names the rule that found it. A comment of the form
+//
+//	// lint:ignore rule[,rule...] [reason]
+//
+// suppresses those rules on its own line, or on the next line when it is
+// the only thing on its line.
+package lint
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/diag"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/parser"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The rules.
+const (
+	UnusedBinding      = "unused-binding"
+	UnusedParameter    = "unused-parameter"
+	ShadowedName       = "shadowed-name"
+	UnreachableCode    = "unreachable-code"
+	UndefinedFunction  = "undefined-function"
+	DuplicateKey       = "duplicate-key"
+	ConstantComparison = "constant-comparison"
+	ArityMismatch      = "arity-mismatch"
+)
+
+// Rules lists every rule, in the order they are described above.
+var Rules = []string{
+	UnusedBinding,
+	UnusedParameter,
+	ShadowedName,
+	UnreachableCode,
+	UndefinedFunction,
+	DuplicateKey,
+	ConstantComparison,
+	ArityMismatch,
+}
+
+// Source parses src, taking positions to be in filename, and returns the
+// problems in it that comments do not suppress, as warnings with the rule as
+// their code. When src does not parse the error is a diag.List.
+func Source(filename string, src []byte) ([]diag.Diagnostic, error) {
+	l := lexer.NewFile(filename, string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		return nil, diag.List(p.Diagnostics())
+	}
+	return Suppress(Program(program), l.Comments()), nil
+}
+
+// Program returns the problems in program, ordered by position.
+func Program(program *ast.Program) []diag.Diagnostic {
+	l := &linter{}
+	l.push(false)
+	l.statements(program.Statements)
+	l.pop()
+
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		a, b := l.problems[i].Pos(), l.problems[j].Pos()
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return l.problems
+}
+
+// Suppress leaves out the problems that lint:ignore comments cover.
+func Suppress(problems []diag.Diagnostic, comments []lexer.Comment) []diag.Diagnostic {
+	ignored := make(map[int]map[string]bool)
+	for _, c := range comments {
+		fields := strings.Fields(strings.TrimPrefix(c.Text, "//"))
+		if len(fields) < 2 || fields[0] != "lint:ignore" {
+			continue
+		}
+		line := c.Pos.Line
+		if !c.Trailing {
+			line++
+		}
+		if ignored[line] == nil {
+			ignored[line] = make(map[string]bool)
+		}
+		for _, rule := range strings.Split(fields[1], ",") {
+			ignored[line][rule] = true
+		}
+	}
+
+	var kept []diag.Diagnostic
+	for _, p := range problems {
+		if !ignored[p.Pos().Line][p.Code] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+type binding struct {
+	ident     *ast.Identifier
+	parameter bool
+	used      bool
+	function  *ast.FunctionLiteral
+}
+
+// scope holds the names bound in a function or catch block, or at the top
+// level. As in the evaluator, if blocks bind names in the scope around them.
+type scope struct {
+	names map[string]*binding
+	local bool
+	outer *scope
+}
+
+type linter struct {
+	scope    *scope
+	problems []diag.Diagnostic
+}
+
+func (l *linter) report(span diag.Span, rule, format string, args ...interface{}) *diag.Diagnostic {
+	l.problems = append(l.problems, diag.Diagnostic{
+		Severity: diag.Warning,
+		Span:     span,
+		Message:  fmt.Sprintf(format, args...),
+		Code:     rule,
+	})
+	return &l.problems[len(l.problems)-1]
+}
+
+func (l *linter) push(local bool) {
+	l.scope = &scope{names: make(map[string]*binding), local: local, outer: l.scope}
+}
+
+// pop ends the current scope, reporting the names it bound that were never
+// used. Top-level bindings may be used by whatever runs the program, so
+// only those of functions and catch blocks count.
+func (l *linter) pop() {
+	s := l.scope
+	l.scope = s.outer
+	if !s.local {
+		return
+	}
+	for _, b := range s.names {
+		if b.used || strings.HasPrefix(b.ident.Value, "_") {
+			continue
+		}
+		var d *diag.Diagnostic
+		if b.parameter {
+			d = l.report(diag.TokenSpan(b.ident.Token), UnusedParameter, "parameter %s is never used", b.ident.Value)
+		} else {
+			d = l.report(diag.TokenSpan(b.ident.Token), UnusedBinding, "%s is bound but never used", b.ident.Value)
+		}
+		d.Fix = &diag.Fix{
+			Message:     fmt.Sprintf("rename it _%s to show it is meant to be unused", b.ident.Value),
+			Span:        d.Span,
+			Replacement: "_" + b.ident.Value,
+		}
+	}
+}
+
+// bind adds ident to the current scope, unless it is already there, and
+// reports it if it hides a name from outside. A name bound again no longer
+// says which function a call to it reaches, so its arity goes unchecked.
+func (l *linter) bind(ident *ast.Identifier, parameter bool, value ast.Expression) {
+	if b, ok := l.scope.names[ident.Value]; ok && !parameter {
+		b.function = nil
+		return
+	}
+	if outer := l.scope.outer.lookup(ident.Value); outer != nil {
+		l.report(diag.TokenSpan(ident.Token), ShadowedName, "%s shadows the %s declared at %s", ident.Value, outer.kind(), outer.ident.Pos())
+	} else if isBuiltin(ident.Value) {
+		l.report(diag.TokenSpan(ident.Token), ShadowedName, "%s shadows the builtin of the same name", ident.Value)
+	}
+	fn, _ := value.(*ast.FunctionLiteral)
+	l.scope.names[ident.Value] = &binding{ident: ident, parameter: parameter, function: fn}
+}
+
+func (b *binding) kind() string {
+	if b.parameter {
+		return "parameter"
+	}
+	return "binding"
+}
+
+func (s *scope) lookup(name string) *binding {
+	for ; s != nil; s = s.outer {
+		if b, ok := s.names[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+var builtins = func() map[string]bool {
+	names := make(map[string]bool)
+	for _, name := range evaluator.BuiltinNames() {
+		names[name] = true
+	}
+	return names
+}()
+
+func isBuiltin(name string) bool {
+	return builtins[name]
+}
+
+// declare binds the names that lets in stmts declare, looking into if and
+// try blocks but not into functions or catch blocks, which have scopes of
+// their own. Doing this before anything else lets functions refer to names
+// bound further down.
+func (l *linter) declare(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			l.bind(stmt.Name, false, stmt.Value)
+		case *ast.ExpressionStatement:
+			l.declareBlocks(stmt.Expression)
+		}
+	}
+}
+
+func (l *linter) declareBlocks(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.IfExpression:
+		l.declare(exp.Consequence.Statements)
+		if exp.Alternative != nil {
+			l.declare(exp.Alternative.Statements)
+		}
+	case *ast.TryExpression:
+		l.declare(exp.Block.Statements)
+		if exp.Finally != nil {
+			l.declare(exp.Finally.Statements)
+		}
+	}
+}
+
+func (l *linter) statements(stmts []ast.Statement) {
+	l.declare(stmts)
+	l.block(stmts)
+}
+
+// block checks stmts, reporting the first that follows a return or throw.
+func (l *linter) block(stmts []ast.Statement) {
+	for i, stmt := range stmts {
+		l.statement(stmt)
+		switch stmt.(type) {
+		case *ast.ReturnStatement, *ast.ThrowStatement:
+			if i+1 < len(stmts) {
+				l.report(diag.Span{Start: stmts[i+1].Pos()}, UnreachableCode, "statement can never run, as it follows a %s", stmt.TokenLiteral())
+				for _, rest := range stmts[i+1:] {
+					l.statement(rest)
+				}
+				return
+			}
+		}
+	}
+}
+
+func (l *linter) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		l.expression(stmt.Value)
+	case *ast.ReturnStatement:
+		l.expression(stmt.ReturnValue)
+	case *ast.ThrowStatement:
+		l.expression(stmt.Value)
+	case *ast.ExpressionStatement:
+		l.expression(stmt.Expression)
+	}
+}
+
+func (l *linter) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if b := l.scope.lookup(exp.Value); b != nil {
+			b.used = true
+		}
+	case *ast.PrefixExpression:
+		l.expression(exp.Right)
+	case *ast.InfixExpression:
+		l.comparison(exp)
+		l.expression(exp.Left)
+		l.expression(exp.Right)
+	case *ast.IfExpression:
+		l.expression(exp.Condition)
+		l.block(exp.Consequence.Statements)
+		if exp.Alternative != nil {
+			l.block(exp.Alternative.Statements)
+		}
+	case *ast.TryExpression:
+		l.block(exp.Block.Statements)
+		if exp.Catch != nil {
+			l.push(true)
+			l.bind(exp.Parameter, true, nil)
+			l.statements(exp.Catch.Statements)
+			l.pop()
+		}
+		if exp.Finally != nil {
+			l.block(exp.Finally.Statements)
+		}
+	case *ast.FunctionLiteral:
+		l.push(true)
+		for _, param := range exp.Parameters {
+			l.bind(param, true, nil)
+		}
+		l.statements(exp.Body.Statements)
+		l.pop()
+	case *ast.CallExpression:
+		l.call(exp)
+		l.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			l.expression(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			l.expression(el)
+		}
+	case *ast.HashLiteral:
+		l.hash(exp)
+	case *ast.IndexExpression:
+		l.expression(exp.Left)
+		l.expression(exp.Index)
+	case *ast.SliceExpression:
+		l.expression(exp.Left)
+		if exp.Start != nil {
+			l.expression(exp.Start)
+		}
+		if exp.End != nil {
+			l.expression(exp.End)
+		}
+	case *ast.TemplateLiteral:
+		for _, e := range exp.Expressions {
+			l.expression(e)
+		}
+	}
+}
+
+// call checks that call names something that can be called, and passes as
+// many arguments as the function takes when it can tell which that is.
+func (l *linter) call(call *ast.CallExpression) {
+	var fn *ast.FunctionLiteral
+	switch callee := call.Function.(type) {
+	case *ast.FunctionLiteral:
+		fn = callee
+	case *ast.Identifier:
+		b := l.scope.lookup(callee.Value)
+		if b == nil && !isBuiltin(callee.Value) {
+			l.report(diag.TokenSpan(callee.Token), UndefinedFunction, "%s is not defined", callee.Value)
+			return
+		}
+		if b != nil {
+			fn = b.function
+		}
+	}
+
+	if fn != nil && len(fn.Parameters) != len(call.Arguments) {
+		l.report(diag.Span{Start: call.Pos()}, ArityMismatch, "call passes %s to a function that takes %d", arguments(len(call.Arguments)), len(fn.Parameters))
+	}
+}
+
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
+// hash reports keys given more than once, as far as literals show it.
+func (l *linter) hash(hash *ast.HashLiteral) {
+	keys := make([]ast.Expression, 0, len(hash.Pairs))
+	for key := range hash.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i].Pos(), keys[j].Pos()
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if literal, ok := literalKey(key); ok {
+			if seen[literal] {
+				text := key.String()
+				if _, ok := key.(*ast.StringLiteral); ok {
+					text = strconv.Quote(text)
+				}
+				l.report(diag.Span{Start: key.Pos()}, DuplicateKey, "key %s appears more than once", text)
+			}
+			seen[literal] = true
+		}
+		l.expression(key)
+		l.expression(hash.Pairs[key])
+	}
+}
+
+// literalKey identifies the value of a literal key, telling apart values
+// that look alike but have different types.
+func literalKey(exp ast.Expression) (string, bool) {
+	switch exp := exp.(type) {
+	case *ast.StringLiteral:
+		return "string " + exp.Value, true
+	case *ast.IntegerLiteral:
+		return fmt.Sprintf("integer %d", exp.Value), true
+	case *ast.Boolean:
+		return fmt.Sprintf("boolean %t", exp.Value), true
+	}
+	return "", false
+}
+
+// comparison reports comparisons whose result does not depend on anything:
+// of literals, or of a name with itself.
+func (l *linter) comparison(exp *ast.InfixExpression) {
+	switch exp.Operator {
+	case "==", "!=", "<", ">":
+	default:
+		return
+	}
+
+	var result bool
+	if isIdentifier(exp.Left) && isIdentifier(exp.Right) && exp.Left.String() == exp.Right.String() {
+		result = exp.Operator == "=="
+	} else {
+		var ok bool
+		if result, ok = compareLiterals(exp.Operator, exp.Left, exp.Right); !ok {
+			return
+		}
+	}
+	l.report(diag.Span{Start: exp.Pos()}, ConstantComparison, "comparison is always %t", result)
+}
+
+func isIdentifier(exp ast.Expression) bool {
+	_, ok := exp.(*ast.Identifier)
+	return ok
+}
+
+func compareLiterals(operator string, left, right ast.Expression) (bool, bool) {
+	switch left := left.(type) {
+	case *ast.IntegerLiteral:
+		switch right := right.(type) {
+		case *ast.IntegerLiteral:
+			switch operator {
+			case "==":
+				return left.Value == right.Value, true
+			case "!=":
+				return left.Value != right.Value, true
+			case "<":
+				return left.Value < right.Value, true
+			case ">":
+				return left.Value > right.Value, true
+			}
+		case *ast.Boolean:
+			return operator == "!=", operator == "==" || operator == "!="
+		}
+	case *ast.Boolean:
+		switch right := right.(type) {
+		case *ast.Boolean:
+			switch operator {
+			case "==":
+				return left.Value == right.Value, true
+			case "!=":
+				return left.Value != right.Value, true
+			}
+		case *ast.IntegerLiteral:
+			return operator == "!=", operator == "==" || operator == "!="
+		}
+	}
+	return false, false
+}
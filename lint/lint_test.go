@@ -0,0 +1,85 @@
+package lint
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let f = fn(x) { x }; f(1)", nil},
+		{"let f = fn() { let a = 1; 2 }", []string{"1:20: a is bound but never used (unused-binding)"}},
+		{"let unused = 1", nil},
+		{"let f = fn(x, _y) { 1 }", []string{"1:12: parameter x is never used (unused-parameter)"}},
+		{"try { 1 } catch (e) { 2 }", []string{"1:18: parameter e is never used (unused-parameter)"}},
+		{"let f = fn() { if (true) { let a = 1 }; a }", nil},
+		{"let f = fn() { g() }; let g = fn() { 1 }", nil},
+		{
+			"let x = 1; let f = fn(x) { x }",
+			[]string{"1:23: x shadows the binding declared at 1:5 (shadowed-name)"},
+		},
+		{"let f = fn(len) { len }", []string{"1:12: len shadows the builtin of the same name (shadowed-name)"}},
+		{"let x = 1; let x = 2", nil},
+		{
+			"let f = fn() { return 1; puts(2); 3 }",
+			[]string{"1:26: statement can never run, as it follows a return (unreachable-code)"},
+		},
+		{"if (true) { throw 1; 2 }", []string{"1:22: statement can never run, as it follows a throw (unreachable-code)"}},
+		{"nope(1); puts(1)", []string{"1:1: nope is not defined (undefined-function)"}},
+		{"let f = fn(g) { g() }", nil},
+		{
+			`{"a": 1, "b": 2, "a": 3, 1: 4, true: 5, "1": 6, 1: 7}`,
+			[]string{
+				`1:18: key "a" appears more than once (duplicate-key)`,
+				`1:49: key 1 appears more than once (duplicate-key)`,
+			},
+		},
+		{
+			"1 == 1; 1 < 2; true != false; 1 == true; let x = 1; x == x; x > x; x == 1; 1 + 1",
+			[]string{
+				"1:1: comparison is always true (constant-comparison)",
+				"1:9: comparison is always true (constant-comparison)",
+				"1:16: comparison is always true (constant-comparison)",
+				"1:31: comparison is always false (constant-comparison)",
+				"1:53: comparison is always true (constant-comparison)",
+				"1:61: comparison is always false (constant-comparison)",
+			},
+		},
+		{
+			"let add = fn(a, b) { a + b }; add(1); add(1, 2); fn(x) { x }(1, 2)",
+			[]string{
+				"1:31: call passes 1 argument to a function that takes 2 (arity-mismatch)",
+				"1:50: call passes 2 arguments to a function that takes 1 (arity-mismatch)",
+			},
+		},
+		{"let f = fn(a) { a }; let f = 1; f(1, 2)", nil},
+		{"let len = fn(a, b) { a + b }; len(1, 2)", []string{"1:5: len shadows the builtin of the same name (shadowed-name)"}},
+		{"nope() // lint:ignore undefined-function generated elsewhere", nil},
+		{"// lint:ignore undefined-function,arity-mismatch\nnope()\nnope()", []string{"3:1: nope is not defined (undefined-function)"}},
+		{"// lint:ignore unused-binding\nnope()", []string{"2:1: nope is not defined (undefined-function)"}},
+	}
+
+	for i, tt := range tests {
+		problems, err := Source("", []byte(tt.input))
+		if err != nil {
+			t.Errorf("tests[%d] unexpected error: %s", i, err)
+			continue
+		}
+		var got []string
+		for _, p := range problems {
+			got = append(got, p.String())
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("tests[%d] wrong problems, expected=%q, got=%q", i, tt.expected, got)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	if _, err := Source("", []byte("let = 1")); err == nil {
+		t.Fatalf("expected an error for source that does not parse")
+	}
+}
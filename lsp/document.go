@@ -0,0 +1,343 @@
+package lsp
+
+import (
+	"monkey/ast"
+	"monkey/diag"
+	"monkey/lexer"
+	"monkey/lint"
+	"monkey/parser"
+	"monkey/token"
+	"strings"
+	"unicode/utf8"
+)
+
+// binding is a name bound by let or as a parameter, with the identifiers
+// that refer to it.
+type binding struct {
+	name      *ast.Identifier
+	parameter bool
+	value     ast.Expression
+	refs      []*ast.Identifier
+}
+
+// scope is a function, a catch block or the whole document, with the names
+// bound in it. As in the evaluator, if blocks bind names in the scope
+// around them. A scope of a function or catch block runs from start to its
+// closing brace at end.
+type scope struct {
+	start, end token.Position
+	names      map[string]*binding
+	outer      *scope
+	children   []*scope
+}
+
+func (s *scope) lookup(name string) *binding {
+	for ; s != nil; s = s.outer {
+		if b, ok := s.names[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+func (s *scope) contains(pos token.Position) bool {
+	return s.outer == nil || !before(pos, s.start) && before(pos, s.end)
+}
+
+// document is an open text document, parsed as far as it goes, with what
+// the requests ask about it worked out.
+type document struct {
+	uri         string
+	text        string
+	lines       []string
+	program     *ast.Program
+	diagnostics []diag.Diagnostic
+
+	// idents holds every identifier in the program, and bindings the
+	// binding of each that names one.
+	idents   []*ast.Identifier
+	bindings map[*ast.Identifier]*binding
+	root     *scope
+	scope    *scope
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lines: strings.Split(text, "\n"), bindings: make(map[*ast.Identifier]*binding)}
+	l := lexer.New(text)
+	p := parser.New(l)
+	d.program = p.ParseProgram()
+	d.diagnostics = p.Diagnostics()
+	if len(d.diagnostics) == 0 {
+		d.diagnostics = lint.Suppress(lint.Program(d.program), l.Comments())
+	}
+
+	d.root = &scope{names: make(map[string]*binding)}
+	d.scope = d.root
+	d.statements(d.program.Statements)
+	return d
+}
+
+// identAt returns the identifier at pos, counting the place just after it.
+func (d *document) identAt(pos token.Position) *ast.Identifier {
+	for _, ident := range d.idents {
+		start := ident.Token.Pos
+		if start.Line == pos.Line && start.Column <= pos.Column && pos.Column <= start.Column+len(ident.Value) {
+			return ident
+		}
+	}
+	return nil
+}
+
+// scopeAt returns the innermost scope around pos.
+func (d *document) scopeAt(pos token.Position) *scope {
+	s := d.root
+	for {
+		inner := s
+		for _, child := range s.children {
+			if child.contains(pos) {
+				inner = child
+				break
+			}
+		}
+		if inner == s {
+			return s
+		}
+		s = inner
+	}
+}
+
+func (d *document) push(start, end token.Position) {
+	s := &scope{start: start, end: end, names: make(map[string]*binding), outer: d.scope}
+	d.scope.children = append(d.scope.children, s)
+	d.scope = s
+}
+
+func (d *document) pop() {
+	d.scope = d.scope.outer
+}
+
+// bind adds name to the current scope. Binding a name again in the same
+// scope sets the same variable, so counts as a reference to it.
+func (d *document) bind(name *ast.Identifier, parameter bool, value ast.Expression) {
+	d.idents = append(d.idents, name)
+	if b, ok := d.scope.names[name.Value]; ok && !parameter {
+		b.refs = append(b.refs, name)
+		d.bindings[name] = b
+		return
+	}
+	b := &binding{name: name, parameter: parameter, value: value}
+	d.scope.names[name.Value] = b
+	d.bindings[name] = b
+}
+
+// statements binds the names lets in stmts declare before going through
+// them, so that functions can refer to names bound further down.
+func (d *document) statements(stmts []ast.Statement) {
+	d.declare(stmts)
+	d.block(stmts)
+}
+
+func (d *document) declare(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			d.bind(stmt.Name, false, stmt.Value)
+		case *ast.ExpressionStatement:
+			switch exp := stmt.Expression.(type) {
+			case *ast.IfExpression:
+				d.declare(exp.Consequence.Statements)
+				if exp.Alternative != nil {
+					d.declare(exp.Alternative.Statements)
+				}
+			case *ast.TryExpression:
+				d.declare(exp.Block.Statements)
+				if exp.Finally != nil {
+					d.declare(exp.Finally.Statements)
+				}
+			}
+		}
+	}
+}
+
+func (d *document) block(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			d.expression(stmt.Value)
+		case *ast.ReturnStatement:
+			d.expression(stmt.ReturnValue)
+		case *ast.ThrowStatement:
+			d.expression(stmt.Value)
+		case *ast.ExpressionStatement:
+			d.expression(stmt.Expression)
+		}
+	}
+}
+
+func (d *document) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		d.idents = append(d.idents, exp)
+		if b := d.scope.lookup(exp.Value); b != nil {
+			b.refs = append(b.refs, exp)
+			d.bindings[exp] = b
+		}
+	case *ast.PrefixExpression:
+		d.expression(exp.Right)
+	case *ast.InfixExpression:
+		d.expression(exp.Left)
+		d.expression(exp.Right)
+	case *ast.IfExpression:
+		d.expression(exp.Condition)
+		d.block(exp.Consequence.Statements)
+		if exp.Alternative != nil {
+			d.block(exp.Alternative.Statements)
+		}
+	case *ast.TryExpression:
+		d.block(exp.Block.Statements)
+		if exp.Catch != nil {
+			d.push(exp.Parameter.Pos(), exp.Catch.End)
+			d.bind(exp.Parameter, true, nil)
+			d.statements(exp.Catch.Statements)
+			d.pop()
+		}
+		if exp.Finally != nil {
+			d.block(exp.Finally.Statements)
+		}
+	case *ast.FunctionLiteral:
+		d.push(exp.Pos(), exp.Body.End)
+		for _, param := range exp.Parameters {
+			d.bind(param, true, nil)
+		}
+		d.statements(exp.Body.Statements)
+		d.pop()
+	case *ast.CallExpression:
+		d.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			d.expression(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			d.expression(el)
+		}
+	case *ast.HashLiteral:
+		for key, value := range exp.Pairs {
+			d.expression(key)
+			d.expression(value)
+		}
+	case *ast.IndexExpression:
+		d.expression(exp.Left)
+		d.expression(exp.Index)
+	case *ast.SliceExpression:
+		d.expression(exp.Left)
+		if exp.Start != nil {
+			d.expression(exp.Start)
+		}
+		if exp.End != nil {
+			d.expression(exp.End)
+		}
+	case *ast.TemplateLiteral:
+		for _, e := range exp.Expressions {
+			d.expression(e)
+		}
+	}
+}
+
+// symbols returns the lets in stmts, with those inside the functions they
+// bind as their children.
+func (d *document) symbols(stmts []ast.Statement) []DocumentSymbol {
+	var symbols []DocumentSymbol
+	for _, stmt := range stmts {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+		selection := d.identRange(let.Name)
+		symbol := DocumentSymbol{
+			Name:           let.Name.Value,
+			Kind:           SymbolVariable,
+			Range:          Range{Start: d.position(let.Pos()), End: selection.End},
+			SelectionRange: selection,
+		}
+		if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+			symbol.Kind = SymbolFunction
+			symbol.Range.End = d.position(fn.Body.End.Advance("}"))
+			symbol.Children = d.symbols(fn.Body.Statements)
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+func before(a, b token.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// position converts pos, whose column counts bytes from 1, to a protocol
+// position.
+func (d *document) position(pos token.Position) Position {
+	line := pos.Line - 1
+	if line < 0 || line >= len(d.lines) {
+		return Position{Line: line}
+	}
+	text := d.lines[line]
+	column := pos.Column - 1
+	if column > len(text) {
+		column = len(text)
+	}
+	if column < 0 {
+		column = 0
+	}
+	return Position{Line: line, Character: utf16Len(text[:column])}
+}
+
+// pos converts a protocol position back to a token position.
+func (d *document) pos(p Position) token.Position {
+	if p.Line < 0 || p.Line >= len(d.lines) {
+		return token.Position{Line: p.Line + 1, Column: 1}
+	}
+	text := d.lines[p.Line]
+	units, offset := 0, 0
+	for offset < len(text) && units < p.Character {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		units += utf16RuneLen(r)
+		offset += size
+	}
+	return token.Position{Line: p.Line + 1, Column: offset + 1}
+}
+
+func (d *document) identRange(ident *ast.Identifier) Range {
+	return Range{Start: d.position(ident.Token.Pos), End: d.position(ident.Token.Pos.Advance(ident.Value))}
+}
+
+// spanRange is the range of span, or of the character at its start when it
+// marks a single place.
+func (d *document) spanRange(span diag.Span) Range {
+	r := Range{Start: d.position(span.Start), End: d.position(span.End)}
+	if !span.End.IsValid() || !before(span.Start, span.End) {
+		r.End = r.Start
+		r.End.Character++
+	}
+	return r
+}
+
+// fullRange covers the whole document.
+func (d *document) fullRange() Range {
+	last := len(d.lines) - 1
+	return Range{End: Position{Line: last, Character: utf16Len(d.lines[last])}}
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+	return n
+}
+
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
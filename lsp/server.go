@@ -0,0 +1,331 @@
+// Package lsp is a Language Server Protocol server for Monkey, which gives
+// editors diagnostics, go to definition, references, hover, completion,
+// document symbols and formatting.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"monkey/ast"
+	"monkey/diag"
+	"monkey/evaluator"
+	"monkey/format"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// Server answers one client, reading requests from in and writing replies
+// and notifications to out.
+type Server struct {
+	conn      *conn
+	documents map[string]*document
+	shutdown  bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{conn: newConn(in, out), documents: make(map[string]*document)}
+}
+
+// Serve handles messages until the client sends exit or closes in. It
+// returns an error if the client exits without asking to shut down first.
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if rerr, ok := err.(*responseError); ok {
+			if err := s.conn.reply(nil, nil, rerr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit before shutdown")
+			}
+			return nil
+		}
+
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			continue
+		}
+		if err := s.conn.reply(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		result := InitializeResult{Capabilities: ServerCapabilities{
+			TextDocumentSync:           textDocumentSyncFull,
+			DefinitionProvider:         true,
+			ReferencesProvider:         true,
+			HoverProvider:              true,
+			CompletionProvider:         &struct{}{},
+			DocumentSymbolProvider:     true,
+			DocumentFormattingProvider: true,
+		}}
+		result.ServerInfo.Name = "monkey"
+		return result, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.open(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.open(params.TextDocument.URI, text)
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		d, pos, err := s.position(msg.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return d.definition(pos), nil
+	case "textDocument/references":
+		var params ReferenceParams
+		d, pos, err := s.position(msg.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return d.references(pos, params.Context.IncludeDeclaration), nil
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		d, pos, err := s.position(msg.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return d.hover(pos), nil
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		d, pos, err := s.position(msg.Params, &params)
+		if err != nil {
+			return nil, err
+		}
+		return d.completion(pos), nil
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		symbols := d.symbols(d.program.Statements)
+		if symbols == nil {
+			symbols = []DocumentSymbol{}
+		}
+		return symbols, nil
+	case "textDocument/formatting":
+		var params DocumentFormattingParams
+		if err := unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		d, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return d.formatting(), nil
+	}
+	if msg.ID == nil {
+		return nil, nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+}
+
+// open parses text as the document at uri and publishes its diagnostics.
+func (s *Server) open(uri, text string) error {
+	d := newDocument(uri, text)
+	s.documents[uri] = d
+
+	diagnostics := []Diagnostic{}
+	for _, dg := range d.diagnostics {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.spanRange(dg.Span),
+			Severity: severity(dg.Severity),
+			Code:     dg.Code,
+			Source:   "monkey",
+			Message:  dg.Message,
+		})
+	}
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	d, ok := s.documents[uri]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: "document not open: " + uri}
+	}
+	return d, nil
+}
+
+// position decodes params, which must hold TextDocumentPositionParams, and
+// returns the document and position they name.
+func (s *Server) position(data json.RawMessage, params interface{}) (*document, token.Position, error) {
+	if err := unmarshal(data, params); err != nil {
+		return nil, token.Position{}, err
+	}
+	var p *TextDocumentPositionParams
+	switch params := params.(type) {
+	case *TextDocumentPositionParams:
+		p = params
+	case *ReferenceParams:
+		p = &params.TextDocumentPositionParams
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, token.Position{}, err
+	}
+	return d, d.pos(p.Position), nil
+}
+
+func unmarshal(data json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func severity(s diag.Severity) int {
+	switch s {
+	case diag.Warning:
+		return SeverityWarning
+	case diag.Info:
+		return SeverityInformation
+	}
+	return SeverityError
+}
+
+func (d *document) definition(pos token.Position) []Location {
+	ident := d.identAt(pos)
+	if ident == nil || d.bindings[ident] == nil {
+		return []Location{}
+	}
+	return []Location{{URI: d.uri, Range: d.identRange(d.bindings[ident].name)}}
+}
+
+func (d *document) references(pos token.Position, declaration bool) []Location {
+	locations := []Location{}
+	ident := d.identAt(pos)
+	if ident == nil || d.bindings[ident] == nil {
+		return locations
+	}
+	b := d.bindings[ident]
+	if declaration {
+		locations = append(locations, Location{URI: d.uri, Range: d.identRange(b.name)})
+	}
+	for _, ref := range b.refs {
+		locations = append(locations, Location{URI: d.uri, Range: d.identRange(ref)})
+	}
+	return locations
+}
+
+func (d *document) hover(pos token.Position) *Hover {
+	ident := d.identAt(pos)
+	if ident == nil {
+		return nil
+	}
+
+	var text string
+	if b := d.bindings[ident]; b != nil {
+		switch fn := b.value.(type) {
+		case *ast.FunctionLiteral:
+			params := []string{}
+			for _, p := range fn.Parameters {
+				params = append(params, p.Value)
+			}
+			text = "```monkey\nlet " + b.name.Value + " = fn(" + strings.Join(params, ", ") + ")\n```"
+		default:
+			kind := "let"
+			if b.parameter {
+				kind = "parameter"
+			}
+			text = "```monkey\n" + kind + " " + b.name.Value + "\n```"
+		}
+	} else if doc, ok := evaluator.BuiltinDoc(ident.Value); ok {
+		text = "```monkey\nbuiltin " + ident.Value + "\n```\n" + doc
+	} else {
+		return nil
+	}
+
+	r := d.identRange(ident)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}
+}
+
+// completion offers the keywords, the builtins and the names bound in the
+// scopes around pos.
+func (d *document) completion(pos token.Position) []CompletionItem {
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+	for s := d.scopeAt(pos); s != nil; s = s.outer {
+		var names []string
+		for name := range s.names {
+			if !seen[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			seen[name] = true
+			b := s.names[name]
+			item := CompletionItem{Label: name, Kind: CompletionVariable, Detail: "let"}
+			if b.parameter {
+				item.Detail = "parameter"
+			}
+			if _, ok := b.value.(*ast.FunctionLiteral); ok {
+				item.Kind = CompletionFunction
+			}
+			items = append(items, item)
+		}
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		if !seen[name] {
+			items = append(items, CompletionItem{Label: name, Kind: CompletionFunction, Detail: "builtin"})
+		}
+	}
+	for _, keyword := range token.Keywords() {
+		items = append(items, CompletionItem{Label: keyword, Kind: CompletionKeyword})
+	}
+	return items
+}
+
+// formatting replaces the whole document with its formatted source, or
+// leaves it alone if it does not parse.
+func (d *document) formatting() []TextEdit {
+	out, err := format.Source([]byte(d.text))
+	if err != nil {
+		return nil
+	}
+	if string(out) == d.text {
+		return []TextEdit{}
+	}
+	return []TextEdit{{Range: d.fullRange(), NewText: string(out)}}
+}
@@ -0,0 +1,357 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// client talks to a Server running in the same process over pipes.
+type client struct {
+	t             *testing.T
+	conn          *conn
+	id            int
+	notifications []*message
+	done          chan error
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	c := &client{t: t, conn: newConn(clientIn, clientOut), done: make(chan error, 1)}
+	go func() {
+		err := NewServer(serverIn, serverOut).Serve()
+		serverOut.Close()
+		c.done <- err
+	}()
+	return c
+}
+
+// call sends a request and decodes the result of its reply into result,
+// keeping the notifications that come before it.
+func (c *client) call(method string, params, result interface{}) {
+	c.t.Helper()
+	c.id++
+	id := json.RawMessage(strconv.Itoa(c.id))
+	data, _ := json.Marshal(params)
+	if err := c.conn.write(&message{ID: &id, Method: method, Params: data}); err != nil {
+		c.t.Fatalf("%s: %s", method, err)
+	}
+	for {
+		msg, err := c.conn.read()
+		if err != nil {
+			c.t.Fatalf("%s: %s", method, err)
+		}
+		if msg.ID == nil {
+			c.notifications = append(c.notifications, msg)
+			continue
+		}
+		if string(*msg.ID) != string(id) {
+			c.t.Fatalf("%s: reply to %s, expected %s", method, *msg.ID, id)
+		}
+		if msg.Error != nil {
+			c.t.Fatalf("%s: error %d %s", method, msg.Error.Code, msg.Error.Message)
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("%s: %s", method, err)
+			}
+		}
+		return
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatalf("%s: %s", method, err)
+	}
+}
+
+// diagnostics returns the diagnostics the server publishes next.
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	msg := c.next()
+	if msg.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got=%s", msg.Method)
+	}
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params
+}
+
+func (c *client) next() *message {
+	c.t.Helper()
+	if len(c.notifications) > 0 {
+		msg := c.notifications[0]
+		c.notifications = c.notifications[1:]
+		return msg
+	}
+	msg, err := c.conn.read()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+func (c *client) close() {
+	c.t.Helper()
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		c.t.Errorf("server returned error: %s", err)
+	}
+}
+
+const uri = "file:///test.mk"
+
+const source = `let add = fn(x, y) {
+  x + y;
+};
+let total = add(1, 2);
+puts(total);
+`
+
+func position(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Line: line, Character: character}}
+}
+
+func span(line, start, end int) Range {
+	return Range{Start: Position{Line: line, Character: start}, End: Position{Line: line, Character: end}}
+}
+
+func open(t *testing.T, text string) *client {
+	c := newClient(t)
+	var result InitializeResult
+	c.call("initialize", map[string]interface{}{}, &result)
+	if !result.Capabilities.DefinitionProvider || result.Capabilities.TextDocumentSync != textDocumentSyncFull {
+		t.Fatalf("wrong capabilities: %+v", result.Capabilities)
+	}
+	c.notify("initialized", map[string]interface{}{})
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, LanguageID: "monkey", Version: 1, Text: text}})
+	return c
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := open(t, "let x = (1 + 2;\n")
+	params := c.diagnostics()
+	if params.URI != uri || len(params.Diagnostics) != 1 {
+		t.Fatalf("wrong diagnostics: %+v", params)
+	}
+	d := params.Diagnostics[0]
+	if d.Severity != SeverityError || d.Range != span(0, 14, 15) || d.Source != "monkey" {
+		t.Errorf("wrong diagnostic: %+v", d)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let f = fn(a) { let b = a; a };\n"}},
+	})
+	params = c.diagnostics()
+	if len(params.Diagnostics) != 1 {
+		t.Fatalf("wrong diagnostics: %+v", params)
+	}
+	d = params.Diagnostics[0]
+	if d.Severity != SeverityWarning || d.Code != "unused-binding" || d.Range != span(0, 20, 21) {
+		t.Errorf("wrong diagnostic: %+v", d)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "puts(1);\n"}},
+	})
+	if params = c.diagnostics(); len(params.Diagnostics) != 0 {
+		t.Errorf("expected no diagnostics, got=%+v", params.Diagnostics)
+	}
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: uri}})
+	if params = c.diagnostics(); params.URI != uri || len(params.Diagnostics) != 0 {
+		t.Errorf("expected diagnostics cleared, got=%+v", params)
+	}
+	c.close()
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	c := open(t, source)
+	c.diagnostics()
+
+	tests := []struct {
+		position   TextDocumentPositionParams
+		definition []Location
+		references []Location
+	}{
+		// x in x + y refers to the parameter.
+		{position(1, 2), []Location{{uri, span(0, 13, 14)}}, []Location{{uri, span(0, 13, 14)}, {uri, span(1, 2, 3)}}},
+		// add in the call, and just after it.
+		{position(3, 12), []Location{{uri, span(0, 4, 7)}}, []Location{{uri, span(0, 4, 7)}, {uri, span(3, 12, 15)}}},
+		{position(3, 15), []Location{{uri, span(0, 4, 7)}}, []Location{{uri, span(0, 4, 7)}, {uri, span(3, 12, 15)}}},
+		// total at its let.
+		{position(3, 5), []Location{{uri, span(3, 4, 9)}}, []Location{{uri, span(3, 4, 9)}, {uri, span(4, 5, 10)}}},
+		// puts is a builtin, and 1 is not a name.
+		{position(4, 1), []Location{}, []Location{}},
+		{position(3, 17), []Location{}, []Location{}},
+	}
+
+	for i, tt := range tests {
+		var definition []Location
+		c.call("textDocument/definition", tt.position, &definition)
+		if !reflect.DeepEqual(definition, tt.definition) {
+			t.Errorf("tests[%d] wrong definition, expected=%+v, got=%+v", i, tt.definition, definition)
+		}
+
+		var references []Location
+		params := ReferenceParams{TextDocumentPositionParams: tt.position}
+		params.Context.IncludeDeclaration = true
+		c.call("textDocument/references", params, &references)
+		if !reflect.DeepEqual(references, tt.references) {
+			t.Errorf("tests[%d] wrong references, expected=%+v, got=%+v", i, tt.references, references)
+		}
+	}
+	c.close()
+}
+
+func TestHover(t *testing.T) {
+	c := open(t, source)
+	c.diagnostics()
+
+	tests := []struct {
+		position TextDocumentPositionParams
+		expected string
+	}{
+		{position(4, 2), "```monkey\nbuiltin puts\n```\nputs(values...) prints each value on a line of its own and returns null."},
+		{position(3, 13), "```monkey\nlet add = fn(x, y)\n```"},
+		{position(1, 6), "```monkey\nparameter y\n```"},
+		{position(4, 7), "```monkey\nlet total\n```"},
+		{position(0, 0), ""},
+	}
+
+	for i, tt := range tests {
+		var hover *Hover
+		c.call("textDocument/hover", tt.position, &hover)
+		got := ""
+		if hover != nil {
+			got = hover.Contents.Value
+		}
+		if got != tt.expected {
+			t.Errorf("tests[%d] wrong hover, expected=%q, got=%q", i, tt.expected, got)
+		}
+	}
+	c.close()
+}
+
+func TestCompletion(t *testing.T) {
+	c := open(t, source)
+	c.diagnostics()
+
+	tests := []struct {
+		position TextDocumentPositionParams
+		expected map[string]int
+		missing  []string
+	}{
+		{position(1, 2), map[string]int{"x": CompletionVariable, "add": CompletionFunction, "total": CompletionVariable, "len": CompletionFunction, "let": CompletionKeyword}, nil},
+		{position(4, 0), map[string]int{"add": CompletionFunction, "puts": CompletionFunction, "fn": CompletionKeyword}, []string{"x", "y"}},
+	}
+
+	for i, tt := range tests {
+		var items []CompletionItem
+		c.call("textDocument/completion", tt.position, &items)
+		kinds := make(map[string]int)
+		for _, item := range items {
+			kinds[item.Label] = item.Kind
+		}
+		for label, kind := range tt.expected {
+			if kinds[label] != kind {
+				t.Errorf("tests[%d] wrong kind of %q, expected=%d, got=%d", i, label, kind, kinds[label])
+			}
+		}
+		for _, label := range tt.missing {
+			if _, ok := kinds[label]; ok {
+				t.Errorf("tests[%d] did not expect %q", i, label)
+			}
+		}
+	}
+	c.close()
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := open(t, "let f = fn(a) {\n  let b = a;\n  b\n};\nlet n = f(1);\n")
+	c.diagnostics()
+
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: uri}}, &symbols)
+	expected := []DocumentSymbol{
+		{Name: "f", Kind: SymbolFunction, Range: Range{Start: Position{0, 0}, End: Position{3, 1}}, SelectionRange: span(0, 4, 5), Children: []DocumentSymbol{
+			{Name: "b", Kind: SymbolVariable, Range: span(1, 2, 7), SelectionRange: span(1, 6, 7)},
+		}},
+		{Name: "n", Kind: SymbolVariable, Range: span(4, 0, 5), SelectionRange: span(4, 4, 5)},
+	}
+	if !reflect.DeepEqual(symbols, expected) {
+		t.Errorf("wrong symbols, expected=%+v, got=%+v", expected, symbols)
+	}
+	c.close()
+}
+
+func TestFormatting(t *testing.T) {
+	c := open(t, "let x=1;\nputs( x )\n")
+	c.diagnostics()
+
+	params := DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: uri}}
+	var edits []TextEdit
+	c.call("textDocument/formatting", params, &edits)
+	expected := []TextEdit{{Range: Range{End: Position{Line: 2}}, NewText: "let x = 1;\nputs(x);\n"}}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("wrong edits, expected=%+v, got=%+v", expected, edits)
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let x = ;\n"}},
+	})
+	c.diagnostics()
+	edits = []TextEdit{}
+	c.call("textDocument/formatting", params, &edits)
+	if edits != nil {
+		t.Errorf("expected no edits for a document that does not parse, got=%+v", edits)
+	}
+	c.close()
+}
+
+func TestUnknownMethod(t *testing.T) {
+	c := open(t, "")
+	c.diagnostics()
+
+	id := json.RawMessage("99")
+	c.conn.write(&message{ID: &id, Method: "textDocument/rename"})
+	msg := c.next()
+	if msg.Error == nil || msg.Error.Code != codeMethodNotFound {
+		t.Errorf("expected method not found, got=%+v", msg)
+	}
+	c.close()
+}
+
+func TestBadContentLength(t *testing.T) {
+	for _, length := range []string{"-1", "abc", strconv.Itoa(maxContentLength + 1)} {
+		in := strings.NewReader("Content-Length: " + length + "\r\n\r\n")
+		err := NewServer(in, io.Discard).Serve()
+		if err == nil || err.Error() != "bad Content-Length \""+length+"\"" {
+			t.Errorf("Content-Length %s: expected error, got=%v", length, err)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	d := newDocument(uri, "let s = \"é😀\"; s\n")
+	// s after the string is byte column 19, UTF-16 character 15.
+	ident := d.identAt(d.pos(Position{Line: 0, Character: 15}))
+	if ident == nil || ident.Token.Pos.Column != 19 {
+		t.Fatalf("wrong identifier: %+v", ident)
+	}
+	if r := d.identRange(ident); r != span(0, 15, 16) {
+		t.Errorf("wrong range: %+v", r)
+	}
+}
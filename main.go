@@ -1,17 +0,0 @@
-package main
-
-import (
-	"fmt"
-	"monkey/repl"
-	"os"
-	"os/user"
-)
-
-func main() {
-	usr, err := user.Current()
-	if err != nil {
-		panic(err)
-	}
-	fmt.Printf("Hello %s! This is the Monkey programming language!\n", usr.Username)
-	repl.Start(os.Stdin, os.Stdout)
-}
@@ -0,0 +1,63 @@
+package object
+
+import "strings"
+
+// Capability is a set of permissions a builtin may need. A runtime grants a
+// set of capabilities, and calling a builtin that needs one it was not
+// granted fails with a permission error.
+type Capability uint
+
+const (
+	CapStdout Capability = 1 << iota
+	CapStdin
+	CapFSRead
+	CapFSWrite
+	CapEnv
+	CapClock
+	CapRandom
+	CapProcess
+
+	NoCapabilities  Capability = 0
+	AllCapabilities Capability = CapStdout | CapStdin | CapFSRead | CapFSWrite | CapEnv | CapClock | CapRandom | CapProcess
+)
+
+var capabilityNames = []struct {
+	capability Capability
+	name       string
+}{
+	{CapStdout, "stdout"},
+	{CapStdin, "stdin"},
+	{CapFSRead, "fs-read"},
+	{CapFSWrite, "fs-write"},
+	{CapEnv, "env"},
+	{CapClock, "clock"},
+	{CapRandom, "random"},
+	{CapProcess, "process"},
+}
+
+func (c Capability) Has(other Capability) bool {
+	return c&other == other
+}
+
+func (c Capability) String() string {
+	var names []string
+	for _, cn := range capabilityNames {
+		if c.Has(cn.capability) {
+			names = append(names, cn.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseCapability looks up a capability by the name String gives it.
+func ParseCapability(name string) (Capability, bool) {
+	for _, cn := range capabilityNames {
+		if cn.name == name {
+			return cn.capability, true
+		}
+	}
+	return NoCapabilities, false
+}
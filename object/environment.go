@@ -1,30 +1,117 @@
 package object
 
+import "sort"
+
+// Environment binds names to values. Globals live in a map; the locals of a
+// function call or catch block live in slots numbered by the resolver, and
+// names records which local each slot holds so they can still be found by
+// name.
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store   map[string]Object
+	slots   []Object
+	names   []string
+	outer   *Environment
+	runtime *Runtime
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
-	env := NewEnvironment()
+	env := &Environment{store: make(map[string]Object), runtime: outer.runtime}
 	env.outer = outer
 	return env
 }
 
+// NewSlotEnvironment makes an environment with one unset slot per name.
+func NewSlotEnvironment(outer *Environment, names []string) *Environment {
+	return &Environment{
+		slots:   make([]Object, len(names)),
+		names:   names,
+		outer:   outer,
+		runtime: outer.runtime,
+	}
+}
+
 func NewEnvironment() *Environment {
+	return NewEnvironmentWithRuntime(NewRuntime())
+}
+
+func NewEnvironmentWithRuntime(runtime *Runtime) *Environment {
 	store := make(map[string]Object)
-	return &Environment{store: store}
+	return &Environment{store: store, runtime: runtime}
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+	if obj, ok := e.store[name]; ok {
+		return obj, true
+	}
+	for i := len(e.names) - 1; i >= 0; i-- {
+		if e.names[i] == name && e.slots[i] != nil {
+			return e.slots[i], true
+		}
 	}
-	return obj, ok
+	if e.outer != nil {
+		return e.outer.Get(name)
+	}
+	return nil, false
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	for i := len(e.names) - 1; i >= 0; i-- {
+		if e.names[i] == name {
+			e.slots[i] = val
+			return val
+		}
+	}
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// Names returns the names bound in e itself, leaving out the environments
+// around it and slots not yet set, in sorted order.
+func (e *Environment) Names() []string {
+	var names []string
+	for name := range e.store {
+		names = append(names, name)
+	}
+	for i, name := range e.names {
+		if e.slots[i] != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	unique := names[:0]
+	for i, name := range names {
+		if i == 0 || name != names[i-1] {
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+// GetSlot returns the local in slot of the environment depth levels out.
+// Until that local is set, name is looked up in the environments beyond it
+// instead, as it would be without the slot.
+func (e *Environment) GetSlot(depth, slot int, name string) (Object, bool) {
+	env := e
+	for ; depth > 0; depth-- {
+		env = env.outer
+	}
+	if obj := env.slots[slot]; obj != nil {
+		return obj, true
+	}
+	if env.outer == nil {
+		return nil, false
+	}
+	return env.outer.Get(name)
+}
+
+func (e *Environment) SetSlot(slot int, val Object) Object {
+	e.slots[slot] = val
+	return val
+}
+
+func (e *Environment) Runtime() *Runtime {
+	return e.runtime
+}
@@ -0,0 +1,161 @@
+package object
+
+import "math/bits"
+
+const (
+	hamtBits = 5
+	hamtMask = 1<<hamtBits - 1
+)
+
+// hamtNode is a node of a hash array mapped trie keyed by HashKey. Each level
+// consumes hamtBits of the key's Value; bitmap marks which of the 32 possible
+// entries are present, and entries holds just those, in order. Keys whose
+// Values are identical but whose Types differ end up together below the last
+// level, in a node searched linearly.
+//
+// Nodes are never modified once built: updates copy the path to the changed
+// entry and share everything else.
+type hamtNode struct {
+	bitmap  uint32
+	entries []hamtEntry
+}
+
+// hamtEntry is either a pair stored under key or, when node is set, a subtree.
+type hamtEntry struct {
+	key  HashKey
+	pair HashPair
+	node *hamtNode
+}
+
+func hamtCollides(shift uint) bool {
+	return shift >= 64
+}
+
+func hamtBit(key HashKey, shift uint) uint32 {
+	return 1 << ((key.Value >> shift) & hamtMask)
+}
+
+func (n *hamtNode) index(bit uint32) int {
+	return bits.OnesCount32(n.bitmap & (bit - 1))
+}
+
+func (n *hamtNode) get(key HashKey, shift uint) (HashPair, bool) {
+	for {
+		if hamtCollides(shift) {
+			for _, e := range n.entries {
+				if e.key == key {
+					return e.pair, true
+				}
+			}
+			return HashPair{}, false
+		}
+		bit := hamtBit(key, shift)
+		if n.bitmap&bit == 0 {
+			return HashPair{}, false
+		}
+		e := n.entries[n.index(bit)]
+		if e.node == nil {
+			return e.pair, e.key == key
+		}
+		n, shift = e.node, shift+hamtBits
+	}
+}
+
+// set returns a copy of n with pair stored under key, and whether the key
+// was not there before.
+func (n *hamtNode) set(key HashKey, pair HashPair, shift uint) (*hamtNode, bool) {
+	entry := hamtEntry{key: key, pair: pair}
+	if hamtCollides(shift) {
+		for i, e := range n.entries {
+			if e.key == key {
+				return n.replace(i, entry), false
+			}
+		}
+		entries := append(append([]hamtEntry{}, n.entries...), entry)
+		return &hamtNode{entries: entries}, true
+	}
+
+	bit := hamtBit(key, shift)
+	i := n.index(bit)
+	if n.bitmap&bit == 0 {
+		entries := make([]hamtEntry, len(n.entries)+1)
+		copy(entries, n.entries[:i])
+		entries[i] = entry
+		copy(entries[i+1:], n.entries[i:])
+		return &hamtNode{bitmap: n.bitmap | bit, entries: entries}, true
+	}
+
+	e := n.entries[i]
+	switch {
+	case e.node != nil:
+		child, added := e.node.set(key, pair, shift+hamtBits)
+		return n.replace(i, hamtEntry{node: child}), added
+	case e.key == key:
+		return n.replace(i, entry), false
+	default:
+		child, _ := (&hamtNode{}).set(e.key, e.pair, shift+hamtBits)
+		child, _ = child.set(key, pair, shift+hamtBits)
+		return n.replace(i, hamtEntry{node: child}), true
+	}
+}
+
+// delete returns a copy of n without key, and whether the key was there.
+func (n *hamtNode) delete(key HashKey, shift uint) (*hamtNode, bool) {
+	if hamtCollides(shift) {
+		for i, e := range n.entries {
+			if e.key == key {
+				return n.remove(i, 0), true
+			}
+		}
+		return n, false
+	}
+
+	bit := hamtBit(key, shift)
+	if n.bitmap&bit == 0 {
+		return n, false
+	}
+	i := n.index(bit)
+	e := n.entries[i]
+	if e.node == nil {
+		if e.key != key {
+			return n, false
+		}
+		return n.remove(i, bit), true
+	}
+
+	child, removed := e.node.delete(key, shift+hamtBits)
+	switch {
+	case !removed:
+		return n, false
+	case len(child.entries) == 0:
+		return n.remove(i, bit), true
+	case len(child.entries) == 1 && child.entries[0].node == nil:
+		return n.replace(i, child.entries[0]), true
+	default:
+		return n.replace(i, hamtEntry{node: child}), true
+	}
+}
+
+func (n *hamtNode) replace(i int, entry hamtEntry) *hamtNode {
+	entries := make([]hamtEntry, len(n.entries))
+	copy(entries, n.entries)
+	entries[i] = entry
+	return &hamtNode{bitmap: n.bitmap, entries: entries}
+}
+
+func (n *hamtNode) remove(i int, bit uint32) *hamtNode {
+	entries := make([]hamtEntry, 0, len(n.entries)-1)
+	entries = append(entries, n.entries[:i]...)
+	entries = append(entries, n.entries[i+1:]...)
+	return &hamtNode{bitmap: n.bitmap &^ bit, entries: entries}
+}
+
+func (n *hamtNode) each(fn func(HashPair)) {
+	for _, e := range n.entries {
+		if e.node != nil {
+			e.node.each(fn)
+		} else {
+			fn(e.pair)
+		}
+	}
+}
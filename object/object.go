@@ -5,11 +5,12 @@ import (
 	"fmt"
 	"hash/fnv"
 	"monkey/ast"
+	"monkey/token"
 	"strings"
 )
 
 type ObjectType string
-type BuiltinFunction func(args ...Object) Object
+type BuiltinFunction func(env *Environment, args ...Object) Object
 
 const (
 	INTEGER_OBJ      = "INTEGER"
@@ -17,6 +18,7 @@ const (
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 	ERROR_OBJ        = "ERROR"
+	ERROR_VALUE_OBJ  = "ERROR_VALUE"
 	FUNCTION_OBJ     = "FUNCTION"
 	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
@@ -29,6 +31,11 @@ type Object interface {
 	Inspect() string
 }
 
+// Integer is shared between every use of the same value wherever the
+// evaluator can manage it: small integers come from a cache and literals are
+// made once. An Integer must therefore never be modified once made, including
+// by a host handed one as a result or as an argument to a bound function;
+// make a new one instead.
 type Integer struct {
 	Value int64
 }
@@ -71,8 +78,39 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// Kinds of Error raised by the interpreter. Scripts and hosts may use kinds
+// of their own as well. GenericError is the kind of a thrown string.
+const (
+	GenericError    = "Error"
+	TypeError       = "TypeError"
+	NameError       = "NameError"
+	IndexError      = "IndexError"
+	ArityError      = "ArityError"
+	ValueError      = "ValueError"
+	IOError         = "IOError"
+	PermissionError = "PermissionError"
+	LimitError      = "LimitError"
+	ExitError       = "ExitError"
+)
+
+// Error is a failure propagating up through evaluation. Scripts can catch it
+// with try/catch unless it is Fatal, as when a resource limit is exceeded.
+// Details optionally carries the values involved, keyed by name. Stack may
+// leave out Elided frames from the middle of a deep stack, between its
+// innermost and outermost MaxTraceFrames.
 type Error struct {
+	Kind    string
 	Message string
+	Details map[string]Object
+	Stack   []Frame
+	Elided  int
+	Fatal   bool
+}
+
+// Error makes an *Error usable as a Go error, so hosts can errors.As the
+// result of an evaluation into it.
+func (e *Error) Error() string {
+	return e.KindName() + ": " + e.Message
 }
 
 func (e *Error) Type() ObjectType {
@@ -82,10 +120,94 @@ func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+// MaxTraceFrames is how many frames a stack trace shows from each end of a
+// deep stack before eliding the middle.
+const MaxTraceFrames = 10
+
+// StackTrace renders the stack, innermost call first, one "at" line per
+// frame.
+func (e *Error) StackTrace() string {
+	return strings.Join(e.TraceLines(), "\n")
+}
+
+// TraceLines is StackTrace split into lines, with a line saying how many
+// frames were left out in place of the middle of a deep stack, and one after
+// each frame that ran tail calls saying how many it replaced.
+func (e *Error) TraceLines() []string {
+	frames, elided := e.Stack, e.Elided
+	if elided == 0 && len(frames) > 2*MaxTraceFrames {
+		elided = len(frames) - 2*MaxTraceFrames
+		frames = append(frames[:MaxTraceFrames:MaxTraceFrames], frames[len(frames)-MaxTraceFrames:]...)
+	}
+	lines := make([]string, 0, 2*len(frames)+1)
+	for i, frame := range frames {
+		if elided > 0 && i == MaxTraceFrames {
+			lines = append(lines, fmt.Sprintf("... %d more frames", elided))
+		}
+		lines = append(lines, frame.String())
+		if frame.TailCalls == 1 {
+			lines = append(lines, "... 1 tail call elided")
+		} else if frame.TailCalls > 1 {
+			lines = append(lines, fmt.Sprintf("... %d tail calls elided", frame.TailCalls))
+		}
+	}
+	return lines
+}
+
+// ExitCode reports the status code the program asked to exit with, if the
+// error is the one the exit builtin raises.
+func (e *Error) ExitCode() (int, bool) {
+	if e.Kind != ExitError {
+		return 0, false
+	}
+	code, ok := e.Details["code"].(*Integer)
+	if !ok {
+		return 0, false
+	}
+	return int(code.Value), true
+}
+
+// KindName is the error's Kind, or GenericError when it has none.
+func (e *Error) KindName() string {
+	if e.Kind == "" {
+		return GenericError
+	}
+	return e.Kind
+}
+
+// ErrorValue is a caught Error held as an ordinary value, so it can be bound,
+// passed around and inspected without propagating; throwing it raises the
+// original Error again.
+type ErrorValue struct {
+	Err *Error
+}
+
+func (ev *ErrorValue) Type() ObjectType {
+	return ERROR_VALUE_OBJ
+}
+func (ev *ErrorValue) Inspect() string {
+	return ev.Err.KindName() + ": " + ev.Err.Message
+}
+
+// Frame is one call on the stack: the function being run and the position
+// reached in it. TailCalls counts the earlier calls that ran in the same
+// frame, each replaced by the tail call it ended with.
+type Frame struct {
+	Function  string
+	Pos       token.Position
+	TailCalls int
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("at %s (%s)", f.Function, f.Pos)
+}
+
 type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Name       string
+	Locals     []string
 }
 
 func (f *Function) Type() ObjectType {
@@ -108,6 +230,7 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+// String, like Integer, may be shared and must never be modified.
 type String struct {
 	Value string
 }
@@ -120,7 +243,9 @@ func (s *String) Inspect() string {
 }
 
 type Builtin struct {
-	Fn BuiltinFunction
+	Name       string
+	Fn         BuiltinFunction
+	Capability Capability
 }
 
 func (b *Builtin) Type() ObjectType {
@@ -130,8 +255,64 @@ func (b *Builtin) Inspect() string {
 	return "builtin function"
 }
 
+// Array is an immutable sequence. It is a window onto a persistent vector,
+// so slicing takes constant time and Push and Set share all but a few nodes
+// with the original. The zero Array is empty.
 type Array struct {
-	Elements []Object
+	vec        *vector
+	start, end int
+}
+
+func NewArray(elements []Object) *Array {
+	vec := emptyVector
+	for _, e := range elements {
+		vec = vec.push(e)
+	}
+	return &Array{vec: vec, end: len(elements)}
+}
+
+func (a *Array) Len() int {
+	return a.end - a.start
+}
+
+// At returns the element at index i, which must be in range.
+func (a *Array) At(i int) Object {
+	return a.vec.get(a.start + i)
+}
+
+// Elements copies the array's elements into a slice.
+func (a *Array) Elements() []Object {
+	elements := make([]Object, a.Len())
+	for i := range elements {
+		elements[i] = a.At(i)
+	}
+	return elements
+}
+
+// Slice returns the elements from start up to but excluding end, which must
+// satisfy 0 <= start <= end <= Len().
+func (a *Array) Slice(start, end int) *Array {
+	return &Array{vec: a.vec, start: a.start + start, end: a.start + end}
+}
+
+// Push returns a new array with value appended.
+func (a *Array) Push(value Object) *Array {
+	vec := a.vec
+	switch {
+	case vec == nil:
+		vec = emptyVector.push(value)
+	case a.end == vec.count:
+		vec = vec.push(value)
+	default:
+		vec = vec.set(a.end, value)
+	}
+	return &Array{vec: vec, start: a.start, end: a.end + 1}
+}
+
+// Set returns a new array with the element at index i, which must be in
+// range, replaced by value.
+func (a *Array) Set(i int, value Object) *Array {
+	return &Array{vec: a.vec.set(a.start+i, value), start: a.start, end: a.end}
 }
 
 func (a *Array) Type() ObjectType {
@@ -140,8 +321,8 @@ func (a *Array) Type() ObjectType {
 func (a *Array) Inspect() string {
 	var out bytes.Buffer
 	var elements []string
-	for _, e := range a.Elements {
-		elements = append(elements, e.Inspect())
+	for i := 0; i < a.Len(); i++ {
+		elements = append(elements, a.At(i).Inspect())
 	}
 	out.WriteString("[")
 	out.WriteString(strings.Join(elements, ", "))
@@ -177,8 +358,59 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash is an immutable map, stored as a hash array mapped trie so that Set
+// and Delete share all but a few nodes with the original. The zero Hash is
+// empty.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	root *hamtNode
+	size int
+}
+
+func (h *Hash) Len() int {
+	return h.size
+}
+
+func (h *Hash) Get(key HashKey) (HashPair, bool) {
+	if h.root == nil {
+		return HashPair{}, false
+	}
+	return h.root.get(key, 0)
+}
+
+// Set returns a new hash with pair stored under key.
+func (h *Hash) Set(key HashKey, pair HashPair) *Hash {
+	root := h.root
+	if root == nil {
+		root = &hamtNode{}
+	}
+	root, added := root.set(key, pair, 0)
+	if added {
+		return &Hash{root: root, size: h.size + 1}
+	}
+	return &Hash{root: root, size: h.size}
+}
+
+// Delete returns a new hash without key, or h itself if key is not in it.
+func (h *Hash) Delete(key HashKey) *Hash {
+	if h.root == nil {
+		return h
+	}
+	root, removed := h.root.delete(key, 0)
+	if !removed {
+		return h
+	}
+	return &Hash{root: root, size: h.size - 1}
+}
+
+// Pairs lists the hash's pairs in an order fixed by their keys.
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, 0, h.size)
+	if h.root != nil {
+		h.root.each(func(pair HashPair) {
+			pairs = append(pairs, pair)
+		})
+	}
+	return pairs
 }
 
 func (h *Hash) Type() ObjectType {
@@ -188,7 +420,7 @@ func (h *Hash) Type() ObjectType {
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 	var pairs []string
-	for _, pair := range h.Pairs {
+	for _, pair := range h.Pairs() {
 		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
 	}
 	out.WriteString("{")
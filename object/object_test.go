@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -13,3 +16,155 @@ func TestStringHashKey(t *testing.T) {
 		t.Fatalf("hash keys should not match")
 	}
 }
+
+func TestCapabilities(t *testing.T) {
+	granted := CapStdout | CapClock
+	if !granted.Has(CapClock) || granted.Has(CapFSRead) {
+		t.Fatalf("Has reported the wrong capabilities for %s", granted)
+	}
+	if !granted.Has(NoCapabilities) {
+		t.Fatalf("every set should include no capabilities")
+	}
+	if granted.String() != "stdout,clock" {
+		t.Fatalf("wrong String(), got=%q", granted.String())
+	}
+	if c, ok := ParseCapability("fs-write"); !ok || c != CapFSWrite {
+		t.Fatalf("ParseCapability failed for fs-write")
+	}
+	if _, ok := ParseCapability("network"); ok {
+		t.Fatalf("ParseCapability accepted an unknown name")
+	}
+}
+
+func TestArray(t *testing.T) {
+	const n = 40000
+	arrays := []*Array{{}}
+	for i := 0; i < n; i++ {
+		arrays = append(arrays, arrays[i].Push(&Integer{Value: int64(i)}))
+	}
+	for _, size := range []int{0, 1, 31, 32, 33, 1056, 1057, n} {
+		arr := arrays[size]
+		if arr.Len() != size {
+			t.Fatalf("wrong length, expected=%d, got=%d", size, arr.Len())
+		}
+		for i := 0; i < size; i++ {
+			if arr.At(i).(*Integer).Value != int64(i) {
+				t.Fatalf("arrays[%d].At(%d) = %s", size, i, arr.At(i).Inspect())
+			}
+		}
+	}
+
+	full := arrays[n]
+	changed := full.Set(5, &String{Value: "five"}).Set(n-1, &String{Value: "last"})
+	if changed.At(5).Inspect() != "five" || changed.At(n-1).Inspect() != "last" || changed.At(6).Inspect() != "6" {
+		t.Fatalf("Set did not replace the elements")
+	}
+	if full.At(5).Inspect() != "5" || full.At(n-1).Inspect() != "39999" {
+		t.Fatalf("Set modified the original array")
+	}
+
+	slice := full.Slice(10, 20)
+	if slice.Len() != 10 || slice.At(0).Inspect() != "10" || slice.At(9).Inspect() != "19" {
+		t.Fatalf("wrong slice %s", slice.Inspect())
+	}
+	pushed := slice.Push(&String{Value: "x"})
+	if pushed.Len() != 11 || pushed.At(10).Inspect() != "x" || full.At(20).Inspect() != "20" {
+		t.Fatalf("pushing onto a slice must not change the array it came from")
+	}
+	if other := slice.Push(&String{Value: "y"}); other.At(10).Inspect() != "y" || pushed.At(10).Inspect() != "x" {
+		t.Fatalf("pushes onto the same slice must not see each other")
+	}
+
+	if got := NewArray([]Object{&Integer{Value: 1}, &Integer{Value: 2}}).Inspect(); got != "[1, 2]" {
+		t.Fatalf("wrong Inspect, got=%s", got)
+	}
+}
+
+func TestHash(t *testing.T) {
+	const n = 5000
+	hash := &Hash{}
+	for i := 0; i < n; i++ {
+		key := &Integer{Value: int64(i)}
+		hash = hash.Set(key.HashKey(), HashPair{Key: key, Value: &Integer{Value: int64(i * 2)}})
+	}
+	for i := 0; i < n; i++ {
+		key := &String{Value: fmt.Sprint("key", i)}
+		hash = hash.Set(key.HashKey(), HashPair{Key: key, Value: key})
+	}
+	if hash.Len() != 2*n || len(hash.Pairs()) != 2*n {
+		t.Fatalf("wrong length, got=%d", hash.Len())
+	}
+	for i := 0; i < n; i++ {
+		pair, ok := hash.Get((&Integer{Value: int64(i)}).HashKey())
+		if !ok || pair.Value.(*Integer).Value != int64(i*2) {
+			t.Fatalf("missing or wrong value for %d", i)
+		}
+		if _, ok := hash.Get((&String{Value: fmt.Sprint("key", i)}).HashKey()); !ok {
+			t.Fatalf("missing key%d", i)
+		}
+	}
+
+	smaller := hash
+	for i := 0; i < n; i += 2 {
+		smaller = smaller.Delete((&Integer{Value: int64(i)}).HashKey())
+	}
+	if smaller.Len() != n+n/2 || hash.Len() != 2*n {
+		t.Fatalf("wrong lengths after Delete, got=%d and %d", smaller.Len(), hash.Len())
+	}
+	for i := 0; i < n; i++ {
+		_, ok := smaller.Get((&Integer{Value: int64(i)}).HashKey())
+		if ok != (i%2 == 1) {
+			t.Fatalf("wrong presence of %d after Delete", i)
+		}
+		if _, ok := hash.Get((&Integer{Value: int64(i)}).HashKey()); !ok {
+			t.Fatalf("Delete modified the original hash")
+		}
+	}
+	if smaller.Delete((&Integer{Value: 0}).HashKey()) != smaller {
+		t.Fatalf("deleting a missing key should return the same hash")
+	}
+}
+
+func TestHashKeysWithEqualValues(t *testing.T) {
+	one := &Integer{Value: 1}
+	yes := &Boolean{Value: true}
+	hash := (&Hash{}).
+		Set(one.HashKey(), HashPair{Key: one, Value: &String{Value: "one"}}).
+		Set(yes.HashKey(), HashPair{Key: yes, Value: &String{Value: "yes"}})
+
+	if hash.Len() != 2 {
+		t.Fatalf("expected both keys, got=%d", hash.Len())
+	}
+	if pair, _ := hash.Get(one.HashKey()); pair.Value.Inspect() != "one" {
+		t.Fatalf("wrong value for 1, got=%s", pair.Value.Inspect())
+	}
+	if pair, _ := hash.Get(yes.HashKey()); pair.Value.Inspect() != "yes" {
+		t.Fatalf("wrong value for true, got=%s", pair.Value.Inspect())
+	}
+
+	hash = hash.Delete(one.HashKey())
+	if _, ok := hash.Get(one.HashKey()); ok || hash.Len() != 1 {
+		t.Fatalf("1 should have been deleted")
+	}
+	if pair, ok := hash.Get(yes.HashKey()); !ok || pair.Value.Inspect() != "yes" {
+		t.Fatalf("true should have survived deleting 1")
+	}
+}
+
+func TestEnvironmentNames(t *testing.T) {
+	global := NewEnvironment()
+	global.Set("b", &Integer{Value: 1})
+	global.Set("a", &Integer{Value: 2})
+
+	local := NewSlotEnvironment(global, []string{"x", "y", "x"})
+	local.SetSlot(0, &Integer{Value: 3})
+	local.SetSlot(2, &Integer{Value: 4})
+	local.Set("z", &Integer{Value: 5})
+
+	if names := global.Names(); fmt.Sprint(names) != "[a b]" {
+		t.Errorf("wrong global names, got=%q", names)
+	}
+	if names := local.Names(); fmt.Sprint(names) != "[x z]" {
+		t.Errorf("wrong local names, got=%q", names)
+	}
+}
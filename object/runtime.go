@@ -0,0 +1,63 @@
+package object
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+)
+
+// DefaultMaxDepth bounds nested function calls so that runaway recursion is
+// reported as a Monkey error long before the Go stack is exhausted.
+const DefaultMaxDepth = 10000
+
+// Runtime is shared by an environment and every environment enclosed by it.
+// It holds the streams that I/O builtins read from and write to, so that an
+// embedding program can redirect them away from the process's own, any
+// builtins registered for this runtime only, the capabilities builtins may
+// use, and the limits that stop a runaway evaluation.
+//
+// Context, when set, is checked periodically during evaluation. MaxSteps
+// bounds the number of nodes evaluated, MaxDepth the number of nested
+// function calls and MaxMemory the bytes allocated for strings, arrays and
+// hashes; zero means no limit. Steps and Allocated count progress towards
+// them, and Stack holds a frame for each function being called, positioned
+// at its call site.
+type Runtime struct {
+	Stdin    *bufio.Reader
+	Stdout   io.Writer
+	Stderr   io.Writer
+	Args     []string
+	Builtins map[string]*Builtin
+
+	Capabilities Capability
+
+	Context  context.Context
+	MaxSteps int
+	MaxDepth int
+	Steps    int
+	Stack    []Frame
+
+	MaxMemory int64
+	Allocated int64
+}
+
+func NewRuntime() *Runtime {
+	return &Runtime{
+		Stdin:    bufio.NewReader(os.Stdin),
+		Stdout:   os.Stdout,
+		Stderr:   os.Stderr,
+		Builtins: make(map[string]*Builtin),
+
+		Capabilities: AllCapabilities,
+		MaxDepth:     DefaultMaxDepth,
+	}
+}
+
+func (rt *Runtime) SetInput(in io.Reader) {
+	if reader, ok := in.(*bufio.Reader); ok {
+		rt.Stdin = reader
+		return
+	}
+	rt.Stdin = bufio.NewReader(in)
+}
@@ -0,0 +1,119 @@
+package object
+
+const (
+	vectorBits  = 5
+	vectorWidth = 1 << vectorBits
+	vectorMask  = vectorWidth - 1
+)
+
+// vector is a persistent vector: a trie of vectorWidth-way nodes holding all
+// elements but the last few, which are kept in a tail so that appending is
+// usually a matter of copying the tail. Updates copy only the path to the
+// changed element and share the rest with the original.
+type vector struct {
+	count int
+	shift uint
+	root  *vectorNode
+	tail  []Object
+}
+
+// vectorNode is a branch with children or, at the bottom of the trie, a leaf
+// with values.
+type vectorNode struct {
+	children []*vectorNode
+	values   []Object
+}
+
+var emptyVector = &vector{shift: vectorBits, root: &vectorNode{}}
+
+func (v *vector) tailOffset() int {
+	if v.count < vectorWidth {
+		return 0
+	}
+	return ((v.count - 1) >> vectorBits) << vectorBits
+}
+
+func (v *vector) get(i int) Object {
+	if i >= v.tailOffset() {
+		return v.tail[i&vectorMask]
+	}
+	node := v.root
+	for level := v.shift; level > 0; level -= vectorBits {
+		node = node.children[(i>>level)&vectorMask]
+	}
+	return node.values[i&vectorMask]
+}
+
+func (v *vector) push(value Object) *vector {
+	if v.count-v.tailOffset() < vectorWidth {
+		tail := make([]Object, len(v.tail)+1)
+		copy(tail, v.tail)
+		tail[len(v.tail)] = value
+		return &vector{count: v.count + 1, shift: v.shift, root: v.root, tail: tail}
+	}
+
+	leaf := &vectorNode{values: v.tail}
+	root, shift := v.root, v.shift
+	if v.count>>vectorBits > 1<<v.shift {
+		root = &vectorNode{children: []*vectorNode{v.root, newVectorPath(v.shift, leaf)}}
+		shift += vectorBits
+	} else {
+		root = v.pushLeaf(v.shift, v.root, leaf)
+	}
+	return &vector{count: v.count + 1, shift: shift, root: root, tail: []Object{value}}
+}
+
+// pushLeaf returns a copy of parent, level bits above the leaves, with leaf
+// added after the last leaf in it.
+func (v *vector) pushLeaf(level uint, parent, leaf *vectorNode) *vectorNode {
+	i := ((v.count - 1) >> level) & vectorMask
+	node := &vectorNode{children: make([]*vectorNode, len(parent.children), i+1)}
+	copy(node.children, parent.children)
+
+	child := leaf
+	if level > vectorBits {
+		if i < len(parent.children) {
+			child = v.pushLeaf(level-vectorBits, parent.children[i], leaf)
+		} else {
+			child = newVectorPath(level-vectorBits, leaf)
+		}
+	}
+	if i < len(node.children) {
+		node.children[i] = child
+	} else {
+		node.children = append(node.children, child)
+	}
+	return node
+}
+
+func newVectorPath(level uint, leaf *vectorNode) *vectorNode {
+	if level == 0 {
+		return leaf
+	}
+	return &vectorNode{children: []*vectorNode{newVectorPath(level-vectorBits, leaf)}}
+}
+
+func (v *vector) set(i int, value Object) *vector {
+	if i >= v.tailOffset() {
+		tail := make([]Object, len(v.tail))
+		copy(tail, v.tail)
+		tail[i&vectorMask] = value
+		return &vector{count: v.count, shift: v.shift, root: v.root, tail: tail}
+	}
+	root := setVectorNode(v.shift, v.root, i, value)
+	return &vector{count: v.count, shift: v.shift, root: root, tail: v.tail}
+}
+
+func setVectorNode(level uint, node *vectorNode, i int, value Object) *vectorNode {
+	if level == 0 {
+		values := make([]Object, len(node.values))
+		copy(values, node.values)
+		values[i&vectorMask] = value
+		return &vectorNode{values: values}
+	}
+	children := make([]*vectorNode, len(node.children))
+	copy(children, node.children)
+	j := (i >> level) & vectorMask
+	children[j] = setVectorNode(level-vectorBits, children[j], i, value)
+	return &vectorNode{children: children}
+}
@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"monkey/ast"
+	"monkey/diag"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
@@ -42,13 +43,15 @@ type Parser struct {
 	l              *lexer.Lexer
 	currToken      token.Token
 	peekToken      token.Token
-	errors         []string
+	diagnostics    []diag.Diagnostic
+	failed         bool
+	depth          int
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l}
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
@@ -58,8 +61,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.TEMPLATE, p.parseTemplateLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
@@ -83,27 +88,59 @@ func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
 }
 
+// Errors returns the messages of Diagnostics, each starting with where the
+// problem is.
 func (p *Parser) Errors() []string {
-	return p.errors
+	return diag.List(p.diagnostics).Strings()
+}
+
+func (p *Parser) Diagnostics() []diag.Diagnostic {
+	return p.diagnostics
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.error(p.peekToken, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
+}
+
+// error reports a problem with tok, unless the statement being parsed has
+// one already: what follows the first is more likely to be confusion caused
+// by it than a mistake of its own. The statement is then left out of the
+// program. It returns the diagnostic added, if any, for the caller to add
+// to.
+func (p *Parser) error(tok token.Token, format string, args ...interface{}) *diag.Diagnostic {
+	if p.failed {
+		return nil
+	}
+	p.failed = true
+	p.diagnostics = append(p.diagnostics, diag.Diagnostic{
+		Severity: diag.Error,
+		Span:     diag.TokenSpan(tok),
+		Message:  fmt.Sprintf(format, args...),
+	})
+	return &p.diagnostics[len(p.diagnostics)-1]
+}
+
+// insertFix suggests adding t after the current token.
+func (p *Parser) insertFix(t token.TokenType) *diag.Fix {
+	end := diag.TokenSpan(p.currToken).End
+	return &diag.Fix{Message: fmt.Sprintf("insert '%s'", t), Span: diag.Span{Start: end, End: end}, Replacement: string(t)}
 }
 
 func (p *Parser) nextToken() {
+	p.depth += nesting(p.currToken.Type)
 	p.currToken = p.peekToken
 	p.peekToken = p.l.NextToken()
 }
 
+// ParseProgram parses statements up to the end of the input. After an error
+// it skips to the next statement and goes on, so Errors has each mistake
+// once and the program holds every statement that parsed.
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
 	for p.currToken.Type != token.EOF {
-		stmt := p.parseStatement()
-		if stmt != nil {
+		if stmt := p.parseStatementOrSkip(); stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
@@ -111,12 +148,64 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// parseStatementOrSkip parses a statement, or if it has an error, skips to
+// where the next one could start and returns nil.
+func (p *Parser) parseStatementOrSkip() ast.Statement {
+	depth := p.depth
+	stmt := p.parseStatement()
+	if p.failed {
+		p.synchronize(depth)
+		return nil
+	}
+	return stmt
+}
+
+// synchronize skips the rest of a statement with an error, leaving the
+// parser on its last token: a semicolon, or the token before a keyword that
+// starts a statement or the brace that ends the block. Only tokens outside
+// brackets opened within the statement count, depth being how many were
+// open where it started. If the error is on the brace that ends the block,
+// the parser stays on it for the block to end there.
+func (p *Parser) synchronize(depth int) {
+	if p.currTokenIs(token.RBRACE) && p.depth+nesting(p.currToken.Type) < depth {
+		p.failed = false
+		return
+	}
+	for !p.peekTokenIs(token.EOF) {
+		if p.depth+nesting(p.currToken.Type) <= depth {
+			if p.currTokenIs(token.SEMICOLON) {
+				break
+			}
+			switch p.peekToken.Type {
+			case token.LET, token.RETURN, token.THROW, token.RBRACE:
+				p.failed = false
+				return
+			}
+		}
+		p.nextToken()
+	}
+	p.failed = false
+}
+
+// nesting is how t changes the number of brackets open.
+func nesting(t token.TokenType) int {
+	switch t {
+	case token.LPAREN, token.LBRACKET, token.LBRACE:
+		return 1
+	case token.RPAREN, token.RBRACKET, token.RBRACE:
+		return -1
+	}
+	return 0
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.currToken.Type {
 	case token.LET:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -133,7 +222,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
-	if p.peekTokenIs(token.SEMICOLON) {
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+	if !p.failed && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
@@ -143,7 +235,17 @@ func (p *Parser) parseReturnStatement() ast.Statement {
 	stmt := &ast.ReturnStatement{Token: p.currToken}
 	p.nextToken()
 	stmt.ReturnValue = p.parseExpression(LOWEST)
-	if p.peekTokenIs(token.SEMICOLON) {
+	if !p.failed && p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseThrowStatement() ast.Statement {
+	stmt := &ast.ThrowStatement{Token: p.currToken}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if !p.failed && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
@@ -167,6 +269,30 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
+// expectClosing is expectPeek for the token t that closes what, which open
+// started.
+func (p *Parser) expectClosing(t token.TokenType, what string, open token.Token) bool {
+	if p.peekTokenIs(t) {
+		p.nextToken()
+		return true
+	}
+	if d := p.error(p.peekToken, "expected '%s' to close %s started at %d:%d", t, what, open.Pos.Line, open.Pos.Column); d != nil {
+		d.Fix = p.insertFix(t)
+	}
+	// When what comes next could not be inside it, it was most likely
+	// never closed, so carry on as if it were. Otherwise synchronize would
+	// take the rest of the program to be inside it.
+	switch p.peekToken.Type {
+	case token.SEMICOLON, token.EOF, token.RBRACE, token.LET, token.RETURN, token.THROW:
+		p.depth--
+	case token.LBRACE:
+		if t != token.RBRACE {
+			p.depth--
+		}
+	}
+	return false
+}
+
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
 	p.prefixParseFns[tokenType] = fn
 }
@@ -180,7 +306,7 @@ func (p *Parser) parseExpressionStatement() ast.Statement {
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
-	if p.peekTokenIs(token.SEMICOLON) {
+	if !p.failed && p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -211,8 +337,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.currToken.Literal, 0, 64)
 
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.currToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.error(p.currToken, "could not parse %q as integer", p.currToken.Literal)
 	}
 
 	lit.Value = value
@@ -237,11 +362,12 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	open := p.currToken
 	p.nextToken()
 
 	expression := p.parseExpression(LOWEST)
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectClosing(token.RPAREN, "parenthesized expression", open) {
 		return nil
 	}
 
@@ -254,11 +380,12 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
+	open := p.currToken
 
 	p.nextToken()
 	expression.Condition = p.parseExpression(LOWEST)
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectClosing(token.RPAREN, "if condition", open) {
 		return nil
 	}
 
@@ -280,6 +407,53 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.currToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	expression.Block = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		open := p.currToken
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		expression.Parameter = &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
+		if !p.expectClosing(token.RPAREN, "catch parameter", open) {
+			return nil
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.error(p.peekToken, "expected catch or finally after try block, got %s instead", p.peekToken.Type)
+		return nil
+	}
+	return expression
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.currToken}
 
@@ -288,31 +462,81 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 
 	lit.Parameters = p.parseFunctionParameters()
-	if !p.expectPeek(token.LBRACE) {
+	if lit.Parameters == nil || !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 	lit.Body = p.parseBlockStatement()
+	markTailCalls(lit.Body, true)
 
 	return lit
 }
 
+// markTailCalls flags the calls in a function body whose result is returned
+// as is: the operand of any return statement, and the final expression when
+// the block itself is in tail position. It looks through if branches, and
+// through catch blocks when there is no finally block to run after them, but
+// not try blocks, which must see the result of the calls inside them.
+func markTailCalls(block *ast.BlockStatement, tail bool) {
+	for i, stmt := range block.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.ReturnStatement:
+			markTailExpression(stmt.ReturnValue, true)
+		case *ast.ExpressionStatement:
+			markTailExpression(stmt.Expression, tail && i == len(block.Statements)-1)
+		}
+	}
+}
+
+func markTailExpression(exp ast.Expression, tail bool) {
+	switch exp := exp.(type) {
+	case *ast.CallExpression:
+		exp.Tail = tail
+	case *ast.IfExpression:
+		markTailCalls(exp.Consequence, tail)
+		if exp.Alternative != nil {
+			markTailCalls(exp.Alternative, tail)
+		}
+	case *ast.TryExpression:
+		if exp.Catch != nil && exp.Finally == nil {
+			markTailCalls(exp.Catch, tail)
+		}
+	}
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.currToken}
 	block.Statements = []ast.Statement{}
 	p.nextToken()
+	depth := p.depth
 	for !p.currTokenIs(token.RBRACE) && !p.currTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt != nil {
+		if stmt := p.parseStatementOrSkip(); stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
+		if p.currTokenIs(token.RBRACE) && p.depth == depth {
+			break
+		}
 		p.nextToken()
 	}
+	if p.currTokenIs(token.EOF) {
+		if d := p.error(p.currToken, "expected '}' to close block started at %d:%d", block.Token.Pos.Line, block.Token.Pos.Column); d != nil {
+			d.Fix = p.insertFix(token.RBRACE)
+		}
+	}
+	block.End = p.currToken.Pos
 	return block
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.error(p.currToken, "no prefix parse function for %s found", t)
+}
+
+// Precedence returns how tightly the infix operator t binds, or LOWEST if t
+// is not one.
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
 }
 
 func (p *Parser) peekPrecedence() int {
@@ -344,21 +568,26 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 }
 
 func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+	open := p.currToken
 	identifiers := []*ast.Identifier{}
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
 		return identifiers
 	}
-	p.nextToken()
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
 	ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
 	identifiers = append(identifiers, ident)
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
-		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
 		ident := &ast.Identifier{Token: p.currToken, Value: p.currToken.Literal}
 		identifiers = append(identifiers, ident)
 	}
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectClosing(token.RPAREN, "parameter list", open) {
 		return nil
 	}
 	return identifiers
@@ -366,13 +595,17 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.currToken, Function: function}
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Arguments = p.parseExpressionList(token.RPAREN, "call")
+	exp.End = p.currToken.Pos
 	return exp
 }
 
-func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+// parseExpressionList parses the comma-separated expressions of what, from
+// its opening token to end.
+func (p *Parser) parseExpressionList(end token.TokenType, what string) []ast.Expression {
+	open := p.currToken
 	args := []ast.Expression{}
-	if p.peekTokenIs(token.RPAREN) {
+	if p.peekTokenIs(end) {
 		p.nextToken()
 		return args
 	}
@@ -383,7 +616,7 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 		p.nextToken()
 		args = append(args, p.parseExpression(LOWEST))
 	}
-	if !p.expectPeek(end) {
+	if !p.expectClosing(end, what, open) {
 		return nil
 	}
 	return args
@@ -393,17 +626,64 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.currToken, Value: p.currToken.Literal}
 }
 
+func (p *Parser) parseTemplateLiteral() ast.Expression {
+	template := &ast.TemplateLiteral{Token: p.currToken}
+	texts, placeholders, err := lexer.SplitTemplate(p.currToken.Literal)
+	if err != nil {
+		p.error(p.currToken, "%s", err)
+		return nil
+	}
+	template.Strings = texts
+	start := p.currToken.Pos.Advance(`"`)
+	for _, placeholder := range placeholders {
+		pos := start.Advance(p.currToken.Literal[:placeholder.Offset])
+		sub := New(lexer.NewAt(placeholder.Source, pos))
+		exp := sub.parseExpression(LOWEST)
+		if !sub.peekTokenIs(token.EOF) {
+			sub.error(sub.peekToken, "unexpected %s in placeholder ${%s}", sub.peekToken.Type, placeholder.Source)
+		}
+		if sub.failed && !p.failed {
+			p.failed = true
+			p.diagnostics = append(p.diagnostics, sub.diagnostics...)
+		}
+		template.Expressions = append(template.Expressions, exp)
+	}
+	return template
+}
+
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.currToken}
-	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Elements = p.parseExpressionList(token.RBRACKET, "array literal")
+	array.End = p.currToken.Pos
 	return array
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.currToken, Left: left}
+	tok := p.currToken
+	p.nextToken()
+	if p.currTokenIs(token.COLON) {
+		return p.parseSliceExpression(tok, left, nil)
+	}
+	index := p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(tok, left, index)
+	}
+	if !p.expectClosing(token.RBRACKET, "index expression", tok) {
+		return nil
+	}
+	return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+}
+
+func (p *Parser) parseSliceExpression(tok token.Token, left ast.Expression, start ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Start: start}
+	if p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		return exp
+	}
 	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
-	if !p.expectPeek(token.RBRACKET) {
+	exp.End = p.parseExpression(LOWEST)
+	if !p.expectClosing(token.RBRACKET, "slice expression", tok) {
 		return nil
 	}
 	return exp
@@ -421,12 +701,15 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 		hash.Pairs[key] = value
-		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
-			return nil
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		} else if !p.peekTokenIs(token.RBRACE) {
+			break
 		}
 	}
-	if !p.expectPeek(token.RBRACE) {
+	if !p.expectClosing(token.RBRACE, "hash literal", hash.Token) {
 		return nil
 	}
+	hash.End = p.currToken.Pos
 	return hash
 }
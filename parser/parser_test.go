@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"monkey/ast"
 	"monkey/lexer"
+	"reflect"
 	"testing"
 )
 
@@ -475,6 +476,26 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("not a let statement, got=%T", program.Statements[0])
+	}
+	function, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("not a function literal, got=%T", stmt.Value)
+	}
+	if function.Name != "myFunction" {
+		t.Fatalf("function literal name wrong. want 'myFunction', got=%q", function.Name)
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := `add(1, 2 * 3, 4 + 5)`
 	l := lexer.New(input)
@@ -587,6 +608,24 @@ func TestParsingArrayLiterals(t *testing.T) {
 	testInfixExpression(t, array.Elements[2], 3, "+", 3)
 }
 
+func TestParsingEmptyArrayLiteral(t *testing.T) {
+	input := "[]"
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("not an array literal")
+	}
+	if len(array.Elements) != 0 {
+		t.Fatalf("incorrect number of elements")
+	}
+}
+
 func TestParsingIndexExpressions(t *testing.T) {
 	input := "myArray[1 + 1]"
 	l := lexer.New(input)
@@ -695,3 +734,278 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"myArray[1:2]", "(myArray[1:2])"},
+		{"myArray[:2]", "(myArray[:2])"},
+		{"myArray[1 + 1:]", "(myArray[(1 + 1):])"},
+		{"myArray[:]", "(myArray[:])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if _, ok := stmt.Expression.(*ast.SliceExpression); !ok {
+			t.Fatalf("not a slice expression, got=%T", stmt.Expression)
+		}
+		if program.String() != tt.expected {
+			t.Fatalf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestParsingTemplateLiterals(t *testing.T) {
+	input := `"hello ${name}, ${1 + 2}!"`
+	l := lexer.New(input)
+	p := New(l)
+
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	template, ok := stmt.Expression.(*ast.TemplateLiteral)
+	if !ok {
+		t.Fatalf("not a template literal, got=%T", stmt.Expression)
+	}
+	if len(template.Strings) != 3 || len(template.Expressions) != 2 {
+		t.Fatalf("wrong number of parts, got strings=%d expressions=%d", len(template.Strings), len(template.Expressions))
+	}
+	if !testIdentifier(t, template.Expressions[0], "name") {
+		return
+	}
+	if !testInfixExpression(t, template.Expressions[1], 1, "+", 2) {
+		return
+	}
+	if template.String() != "hello ${name}, ${(1 + 2)}!" {
+		t.Fatalf("unexpected String(), got=%q", template.String())
+	}
+}
+
+func TestTemplateLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"${1 2}"`, "1:6: unexpected INT in placeholder ${1 2}"},
+		{`"${}"`, "1:4: no prefix parse function for EOF found"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Fatalf("expected error %q, got=%q", tt.expected, errors)
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { x } catch (e) { y }`, "try xcatch(e) y"},
+		{`try { x } finally { z }`, "try xfinally z"},
+		{`try { x } catch (e) { y } finally { z }`, "try xcatch(e) yfinally z"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("incorrect number of statements, got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("not an expression statement, got=%T", program.Statements[0])
+		}
+		if _, ok := stmt.Expression.(*ast.TryExpression); !ok {
+			t.Fatalf("not a try expression, got=%T", stmt.Expression)
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { x }`, "1:10: expected catch or finally after try block, got EOF instead"},
+		{`try { x } catch { y }`, "1:17: expected next token to be (, got { instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Fatalf("expected error %q, got=%q", tt.expected, errors)
+		}
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	p := New(lexer.New(`throw "boom";`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("not a throw statement, got=%T", program.Statements[0])
+	}
+	if stmt.Value.String() != "boom" {
+		t.Fatalf("wrong thrown value, got=%q", stmt.Value.String())
+	}
+}
+
+func TestTailCallMarking(t *testing.T) {
+	input := `fn(n) {
+	if (n > 0) { return a(); }
+	b();
+	let x = c();
+	d() + e();
+	try { f() } catch (err) { g() }
+	try { j() } catch (err) { return k(); } finally { l() }
+	try { m() } catch (err) { return o(); }
+	if (n) { h() } else { try { p() } catch (err) { q() } }
+}`
+	expected := map[string]bool{
+		"a": true, "b": false, "c": false, "d": false, "e": false,
+		"f": false, "g": false, "j": false, "k": false, "l": false,
+		"m": false, "o": true, "p": false, "q": true,
+	}
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	calls := map[string]bool{}
+	var visit func(node ast.Node)
+	visit = func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.Program:
+			for _, s := range node.Statements {
+				visit(s)
+			}
+		case *ast.BlockStatement:
+			for _, s := range node.Statements {
+				visit(s)
+			}
+		case *ast.ExpressionStatement:
+			visit(node.Expression)
+		case *ast.ReturnStatement:
+			visit(node.ReturnValue)
+		case *ast.LetStatement:
+			visit(node.Value)
+		case *ast.InfixExpression:
+			visit(node.Left)
+			visit(node.Right)
+		case *ast.FunctionLiteral:
+			visit(node.Body)
+		case *ast.IfExpression:
+			visit(node.Consequence)
+			if node.Alternative != nil {
+				visit(node.Alternative)
+			}
+		case *ast.TryExpression:
+			visit(node.Block)
+			visit(node.Catch)
+			if node.Finally != nil {
+				visit(node.Finally)
+			}
+		case *ast.CallExpression:
+			calls[node.Function.String()] = node.Tail
+		}
+	}
+	visit(program)
+
+	for name, tail := range expected {
+		got, ok := calls[name]
+		if !ok {
+			t.Fatalf("call to %s not found", name)
+		}
+		if got != tail {
+			t.Errorf("call to %s: expected Tail=%t, got=%t", name, tail, got)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input    string
+		errors   []string
+		expected string
+	}{
+		{
+			"let = 1; let y = 2;\nlet z = f(1, 2;\nz",
+			[]string{
+				"1:5: expected next token to be IDENT, got = instead",
+				"2:15: expected ')' to close call started at 2:10",
+			},
+			"let y = 2;z",
+		},
+		{
+			"let f = fn() {\n  let a = ;\n  a\n};\nf(1",
+			[]string{
+				"2:11: no prefix parse function for ; found",
+				"5:4: expected ')' to close call started at 5:2",
+			},
+			"let f = fn()a;",
+		},
+		{`let g = fn() { {"a" 1} }; g`, []string{"1:21: expected next token to be :, got INT instead"}, "let g = fn();g"},
+		{"if (x { y }; z", []string{"1:7: expected ')' to close if condition started at 1:4"}, "z"},
+		{"let f = fn(1) { x }; y", []string{"1:12: expected next token to be IDENT, got INT instead"}, "y"},
+		{"[1, 2; x", []string{"1:6: expected ']' to close array literal started at 1:1"}, "x"},
+		{`{"a": 1 "b": 2}; x`, []string{"1:9: expected '}' to close hash literal started at 1:1"}, "x"},
+		{"a[1; b)); c", []string{"1:4: expected ']' to close index expression started at 1:2", "1:7: no prefix parse function for ) found"}, "bc"},
+		{"fn() { x", []string{"1:9: expected '}' to close block started at 1:6"}, ""},
+		{"let d = fn(x) { x + };\nlet y = 2;\nputs(y)", []string{"1:21: no prefix parse function for } found"}, "let d = fn(x);let y = 2;puts(y)"},
+		{"if (true) { 1 + }\nx", []string{"1:17: no prefix parse function for } found"}, "iftrue x"},
+	}
+
+	for i, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if !reflect.DeepEqual(p.Errors(), tt.errors) {
+			t.Errorf("tests[%d] wrong errors, expected=%q, got=%q", i, tt.errors, p.Errors())
+		}
+		if program.String() != tt.expected {
+			t.Errorf("tests[%d] wrong program, expected=%q, got=%q", i, tt.expected, program.String())
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	p := New(lexer.New("f(1, 22;\nlet = 1"))
+	p.ParseProgram()
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got=%v", diagnostics)
+	}
+
+	closing := diagnostics[0]
+	if closing.Span.Start.Column != 8 || closing.Span.End.Column != 9 {
+		t.Errorf("wrong span, got=%s-%s", closing.Span.Start, closing.Span.End)
+	}
+	if closing.Fix == nil || closing.Fix.Replacement != ")" || closing.Fix.Span.Start.Column != 8 {
+		t.Errorf("expected a fix inserting ')' after 22, got=%+v", closing.Fix)
+	}
+	if diagnostics[1].Fix != nil || diagnostics[1].Span.Start.Line != 2 {
+		t.Errorf("wrong second diagnostic, got=%+v", diagnostics[1])
+	}
+}
@@ -0,0 +1,460 @@
+package repl
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// errInterrupted is returned by ReadLine when Ctrl-C abandons the line.
+var errInterrupted = errors.New("interrupted")
+
+const maxHistory = 1000
+
+// Keys are the bytes a terminal in raw mode sends for them, or below zero
+// for those it sends as escape sequences.
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlJ     = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+const (
+	keyUp = -1 - iota
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDelete
+	keyUnknown
+)
+
+// lineReader reads the REPL's input a line at a time, showing prompt first.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+type plainReader struct {
+	reader *bufio.Reader
+	out    io.Writer
+}
+
+func (r *plainReader) ReadLine(prompt string) (string, error) {
+	io.WriteString(r.out, prompt)
+	line, err := r.reader.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// editor reads lines a key at a time from a terminal in raw mode, letting
+// the user move around and change the line, recall earlier lines and
+// complete names.
+type editor struct {
+	in       io.Reader
+	out      io.Writer
+	raw      func() (func(), error)
+	complete func(word string) []string
+
+	history []string
+	file    string
+
+	prompt  string
+	buf     []rune
+	pos     int
+	index   int
+	draft   string
+	pending rune
+}
+
+func (e *editor) ReadLine(prompt string) (string, error) {
+	if e.raw != nil {
+		restore, err := e.raw()
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	e.prompt, e.buf, e.pos = prompt, nil, 0
+	e.index, e.draft = len(e.history), ""
+	e.refresh()
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			if err == io.EOF && len(e.buf) > 0 {
+				err = nil
+			}
+			return e.finish(), err
+		}
+
+		switch key {
+		case keyEnter, keyCtrlJ:
+			return e.finish(), nil
+		case keyCtrlC:
+			io.WriteString(e.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteRunes(e.pos, e.pos+1)
+		case keyBackspace, keyCtrlH:
+			e.deleteRunes(e.pos-1, e.pos)
+		case keyDelete:
+			e.deleteRunes(e.pos, e.pos+1)
+		case keyLeft, keyCtrlB:
+			if e.pos > 0 {
+				e.pos--
+			}
+		case keyRight, keyCtrlF:
+			if e.pos < len(e.buf) {
+				e.pos++
+			}
+		case keyHome, keyCtrlA:
+			e.pos = 0
+		case keyEnd, keyCtrlE:
+			e.pos = len(e.buf)
+		case keyCtrlK:
+			e.deleteRunes(e.pos, len(e.buf))
+		case keyCtrlU:
+			e.deleteRunes(0, e.pos)
+		case keyCtrlW:
+			start := e.pos
+			for start > 0 && e.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.buf[start-1] != ' ' {
+				start--
+			}
+			e.deleteRunes(start, e.pos)
+		case keyUp, keyCtrlP:
+			e.recall(e.index - 1)
+		case keyDown, keyCtrlN:
+			e.recall(e.index + 1)
+		case keyTab:
+			e.completeWord()
+		case keyCtrlL:
+			io.WriteString(e.out, "\x1b[H\x1b[2J")
+		case keyCtrlR:
+			accepted, err := e.search()
+			if err != nil {
+				return "", err
+			}
+			if accepted {
+				e.refresh()
+				return e.finish(), nil
+			}
+		default:
+			if key >= ' ' {
+				e.insert([]rune{key})
+			}
+		}
+		e.refresh()
+	}
+}
+
+// finish ends the line on the terminal and adds it to the history.
+func (e *editor) finish() string {
+	io.WriteString(e.out, "\r\n")
+	line := string(e.buf)
+	e.addHistory(line)
+	return line
+}
+
+// refresh redraws the prompt and line and puts the cursor back in place.
+func (e *editor) refresh() {
+	var out bytes.Buffer
+	out.WriteString("\r" + e.prompt + string(e.buf) + "\x1b[K")
+	if n := len(e.buf) - e.pos; n > 0 {
+		fmt.Fprintf(&out, "\x1b[%dD", n)
+	}
+	e.out.Write(out.Bytes())
+}
+
+func (e *editor) insert(runes []rune) {
+	buf := make([]rune, 0, len(e.buf)+len(runes))
+	buf = append(buf, e.buf[:e.pos]...)
+	buf = append(buf, runes...)
+	e.buf = append(buf, e.buf[e.pos:]...)
+	e.pos += len(runes)
+}
+
+// deleteRunes removes the runes from start up to end, as far as they are in
+// the line, leaving the cursor where they were.
+func (e *editor) deleteRunes(start, end int) {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(e.buf) {
+		end = len(e.buf)
+	}
+	if start >= end {
+		return
+	}
+	e.buf = append(e.buf[:start], e.buf[end:]...)
+	e.pos = start
+}
+
+// recall replaces the line with entry i of the history, keeping what was
+// being typed to come back to past the newest entry.
+func (e *editor) recall(i int) {
+	if i < 0 || i > len(e.history) {
+		return
+	}
+	if e.index == len(e.history) {
+		e.draft = string(e.buf)
+	}
+	e.index = i
+	line := e.draft
+	if i < len(e.history) {
+		line = e.history[i]
+	}
+	e.buf = []rune(line)
+	e.pos = len(e.buf)
+}
+
+// search looks back through the history for lines containing what the user
+// types, Ctrl-R stepping to older matches. It reports whether the match was
+// accepted with Enter; other keys leave it in the line to edit and are then
+// handled as usual, and Ctrl-G or Ctrl-C give up on it.
+func (e *editor) search() (bool, error) {
+	var query []rune
+	match, found := len(e.history), ""
+	failing := false
+	find := func(before int) {
+		for i := before - 1; i >= 0; i-- {
+			if strings.Contains(e.history[i], string(query)) {
+				match, found, failing = i, e.history[i], false
+				return
+			}
+		}
+		failing = true
+	}
+
+	for {
+		status := "reverse-i-search"
+		if failing {
+			status = "failing " + status
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", status, string(query), found)
+
+		key, err := e.readKey()
+		if err != nil {
+			return false, err
+		}
+		switch key {
+		case keyCtrlR:
+			find(match)
+		case keyBackspace, keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(len(e.history))
+			}
+		case keyCtrlG, keyCtrlC:
+			return false, nil
+		case keyEnter, keyCtrlJ:
+			e.buf, e.pos = []rune(found), len([]rune(found))
+			return true, nil
+		default:
+			if key >= ' ' {
+				query = append(query, key)
+				if match < len(e.history) {
+					match++
+				}
+				find(match)
+				continue
+			}
+			e.buf, e.pos = []rune(found), len([]rune(found))
+			e.pending = key
+			return false, nil
+		}
+	}
+}
+
+// completeWord completes the name before the cursor as far as the
+// candidates for it agree, and lists them when they go no further.
+func (e *editor) completeWord() {
+	start := e.pos
+	for start > 0 && isNameRune(e.buf[start-1]) {
+		start--
+	}
+	word := string(e.buf[start:e.pos])
+	if word == "" || e.complete == nil {
+		return
+	}
+
+	candidates := e.complete(word)
+	if len(candidates) == 0 {
+		return
+	}
+	prefix := commonPrefix(candidates)
+	if len(prefix) > len(word) {
+		e.insert([]rune(prefix[len(word):]))
+		return
+	}
+	io.WriteString(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+}
+
+func isNameRune(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r == '_'
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// readKey reads the next key pressed, decoding UTF-8 and the escape
+// sequences terminals send for arrows and the like.
+func (e *editor) readKey() (rune, error) {
+	if e.pending != 0 {
+		key := e.pending
+		e.pending = 0
+		return key, nil
+	}
+
+	b, err := e.readByte()
+	if err != nil {
+		return 0, err
+	}
+	if b == keyEscape {
+		return e.readEscape()
+	}
+	if b < utf8.RuneSelf {
+		return rune(b), nil
+	}
+
+	buf := []byte{b}
+	for !utf8.FullRune(buf) {
+		b, err := e.readByte()
+		if err != nil {
+			return 0, err
+		}
+		buf = append(buf, b)
+	}
+	r, _ := utf8.DecodeRune(buf)
+	return r, nil
+}
+
+// readEscape reads the rest of a sequence such as ESC [ A, made of a [ or O,
+// optional parameters and a final letter or ~.
+func (e *editor) readEscape() (rune, error) {
+	b, err := e.readByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return keyUnknown, err
+	}
+	var params []byte
+	for {
+		b, err = e.readByte()
+		if err != nil {
+			return keyUnknown, err
+		}
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+		params = append(params, b)
+	}
+
+	switch string(params) + string(b) {
+	case "A":
+		return keyUp, nil
+	case "B":
+		return keyDown, nil
+	case "C":
+		return keyRight, nil
+	case "D":
+		return keyLeft, nil
+	case "H", "1~", "7~":
+		return keyHome, nil
+	case "F", "4~", "8~":
+		return keyEnd, nil
+	case "3~":
+		return keyDelete, nil
+	}
+	return keyUnknown, nil
+}
+
+func (e *editor) readByte() (byte, error) {
+	var b [1]byte
+	for {
+		n, err := e.in.Read(b[:])
+		if n == 1 {
+			return b[0], nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (e *editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+
+	if e.file == "" {
+		return
+	}
+	f, err := os.OpenFile(e.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+// loadHistory reads the lines kept in file by earlier sessions, and has the
+// editor add to it from now on. A file holding more than the editor keeps is
+// cut down to those lines, so that it does not grow without end.
+func (e *editor) loadHistory(file string) error {
+	e.file = file
+	content, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+		return os.WriteFile(file, []byte(strings.Join(e.history, "\n")+"\n"), 0600)
+	}
+	return nil
+}
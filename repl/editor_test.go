@@ -0,0 +1,172 @@
+package repl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"monkey/object"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEditor(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"hello\r", []string{"hello"}},
+		{"abc\x1b[D\x1b[DX\r", []string{"aXbc"}},
+		{"abc\x01X\x05Y\r", []string{"XabcY"}},
+		{"abc\x02\x02\x06Z\n", []string{"abZc"}},
+		{"abc\x7f\r", []string{"ab"}},
+		{"h\xc3\xa9llo\x1b[D\x1b[D\x1b[D\x1b[3~\r", []string{"hélo"}},
+		{"ab\x1b[H\x04\r", []string{"b"}},
+		{"one two  \x17\r", []string{"one "}},
+		{"abc\x1b[D\x0b\r", []string{"ab"}},
+		{"abc\x1b[D\x15\r", []string{"c"}},
+		{"first\rsecond\r\x1b[A\x1b[A\r", []string{"first", "second", "first"}},
+		{"one\rdraft\x1b[A\x1b[B\r", []string{"one", "draft"}},
+		{"same\r\x10\r\x10\x10\r", []string{"same", "same", "same"}},
+		{"let x = 1\rputs(x)\rlet y = 2\r\x12let\x12\r", []string{"let x = 1", "puts(x)", "let y = 2", "let x = 1"}},
+		{"abc\r\x12b\x05d\r", []string{"abc", "abcd"}},
+		{"abc\rxy\x12a\x07\r", []string{"abc", "xy"}},
+		{"abc\r\x12z\r", []string{"abc", ""}},
+		{"pu\t(1)\r", []string{"puts(1)"}},
+		{"x = le\t\r", []string{"x = le"}},
+		{"no\t\r", []string{"no"}},
+		{"unfinished", []string{"unfinished"}},
+	}
+
+	for i, tt := range tests {
+		e := &editor{in: strings.NewReader(tt.input), out: io.Discard, complete: completeFrom("len", "let", "puts")}
+		lines, err := readLines(e)
+		if err != io.EOF {
+			t.Errorf("tests[%d] expected io.EOF at the end, got=%v", i, err)
+		}
+		if !reflect.DeepEqual(lines, tt.expected) {
+			t.Errorf("tests[%d] wrong lines, expected=%q, got=%q", i, tt.expected, lines)
+		}
+	}
+}
+
+func TestEditorInterrupt(t *testing.T) {
+	e := &editor{in: strings.NewReader("abc\x03def\r"), out: io.Discard}
+	if _, err := e.ReadLine(PROMPT); err != errInterrupted {
+		t.Fatalf("expected errInterrupted, got=%v", err)
+	}
+	if line, err := e.ReadLine(PROMPT); line != "def" || err != nil {
+		t.Fatalf("expected the next line to be read afresh, got=%q, %v", line, err)
+	}
+}
+
+func TestEditorOutput(t *testing.T) {
+	var out bytes.Buffer
+	e := &editor{in: strings.NewReader("le\tab\x1b[D\r"), out: &out, complete: completeFrom("len", "let")}
+	e.ReadLine(PROMPT)
+
+	for _, expected := range []string{"\r\nlen  let\r\n", "\r>> leab\x1b[K\x1b[1D", "\r\n"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("output does not contain %q, got=%q", expected, out.String())
+		}
+	}
+}
+
+func TestEditorHistoryFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), historyFile)
+	if err := os.WriteFile(file, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &editor{in: strings.NewReader("new\r\r\x1b[A\x1b[A\r"), out: io.Discard}
+	if err := e.loadHistory(file); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	lines, _ := readLines(e)
+	if !reflect.DeepEqual(lines, []string{"new", "", "old"}) {
+		t.Fatalf("expected to recall the line from the file, got=%q", lines)
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "old\nnew\nold\n" {
+		t.Fatalf("wrong history file, got=%q", content)
+	}
+}
+
+func TestEditorHistoryFileTrimmed(t *testing.T) {
+	file := filepath.Join(t.TempDir(), historyFile)
+	var content strings.Builder
+	for i := 0; i < maxHistory+500; i++ {
+		fmt.Fprintf(&content, "line %d\n", i)
+	}
+	if err := os.WriteFile(file, []byte(content.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := &editor{out: io.Discard}
+	if err := e.loadHistory(file); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(e.history) != maxHistory || e.history[0] != "line 500" {
+		t.Fatalf("expected the last %d lines, got %d from %q", maxHistory, len(e.history), e.history[0])
+	}
+	trimmed, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(trimmed), "\n"), "\n")
+	if len(lines) != maxHistory || lines[0] != "line 500" || lines[len(lines)-1] != "line 1499" {
+		t.Fatalf("expected the file cut to the last %d lines, got %d", maxHistory, len(lines))
+	}
+}
+
+func TestComplete(t *testing.T) {
+	s := &session{env: object.NewEnvironment()}
+	s.env.Set("lengthy", &object.Integer{Value: 1})
+
+	if got := s.complete("le"); !reflect.DeepEqual(got, []string{"len", "lengthy", "let"}) {
+		t.Errorf("wrong completions, got=%q", got)
+	}
+	if got := s.complete("zz"); len(got) != 0 {
+		t.Errorf("expected no completions, got=%q", got)
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if isTerminal(f.Fd()) {
+		t.Fatalf("a regular file is not a terminal")
+	}
+}
+
+func readLines(e *editor) ([]string, error) {
+	var lines []string
+	for {
+		line, err := e.ReadLine(PROMPT)
+		if err != nil && line == "" {
+			return lines, err
+		}
+		lines = append(lines, line)
+	}
+}
+
+func completeFrom(names ...string) func(string) []string {
+	return func(word string) []string {
+		var matches []string
+		for _, name := range names {
+			if strings.HasPrefix(name, word) {
+				matches = append(matches, name)
+			}
+		}
+		return matches
+	}
+}
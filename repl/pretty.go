@@ -0,0 +1,188 @@
+package repl
+
+import (
+	"io"
+	"monkey/object"
+	"os"
+	"strings"
+)
+
+// printWidth is how wide a value may get before its collections are spread
+// over several lines.
+const printWidth = 80
+
+const colorReset = "\x1b[0m"
+
+var colors = map[object.ObjectType]string{
+	object.INTEGER_OBJ:     "\x1b[33m",
+	object.BOOLEAN_OBJ:     "\x1b[35m",
+	object.NULL_OBJ:        "\x1b[90m",
+	object.STRING_OBJ:      "\x1b[32m",
+	object.FUNCTION_OBJ:    "\x1b[36m",
+	object.BUILTIN_OBJ:     "\x1b[36m",
+	object.ERROR_OBJ:       "\x1b[31m",
+	object.ERROR_VALUE_OBJ: "\x1b[31m",
+}
+
+// printer shows values the way they would be written in source, so that
+// "1" and 1 look different, spreading collections over several lines when
+// they do not fit in width.
+type printer struct {
+	width int
+	color bool
+}
+
+// newPrinter returns a printer for out, which colors values when out is a
+// terminal and NO_COLOR is not set.
+func newPrinter(out io.Writer) *printer {
+	p := &printer{width: printWidth}
+	if f, ok := out.(*os.File); ok && isTerminal(f.Fd()) {
+		p.color = os.Getenv("NO_COLOR") == ""
+	}
+	return p
+}
+
+// Sprint formats obj as if it started column characters into a line.
+func (p *printer) Sprint(obj object.Object, column int) string {
+	var out strings.Builder
+	p.print(&out, obj, column, 0, make(map[object.Object]bool))
+	return out.String()
+}
+
+// print writes obj on one line if it fits after column and otherwise puts
+// each element of a collection on a line of its own, indented two spaces
+// past indent. open holds the collections being printed, so one that
+// contains itself is cut short.
+func (p *printer) print(out *strings.Builder, obj object.Object, column, indent int, open map[object.Object]bool) {
+	var line strings.Builder
+	p.printFlat(&line, obj, open)
+	if column+visibleLen(line.String()) <= p.width || !isCollection(obj) || open[obj] {
+		out.WriteString(line.String())
+		return
+	}
+	open[obj] = true
+	defer delete(open, obj)
+
+	margin := strings.Repeat(" ", indent+2)
+	switch obj := obj.(type) {
+	case *object.Array:
+		out.WriteString("[\n")
+		for i := 0; i < obj.Len(); i++ {
+			out.WriteString(margin)
+			p.print(out, obj.At(i), indent+2, indent+2, open)
+			p.endElement(out, i, obj.Len())
+		}
+		out.WriteString(strings.Repeat(" ", indent) + "]")
+	case *object.Hash:
+		out.WriteString("{\n")
+		pairs := obj.Pairs()
+		for i, pair := range pairs {
+			var key strings.Builder
+			p.printFlat(&key, pair.Key, open)
+			out.WriteString(margin + key.String() + ": ")
+			p.print(out, pair.Value, indent+2+visibleLen(key.String())+2, indent+2, open)
+			p.endElement(out, i, len(pairs))
+		}
+		out.WriteString(strings.Repeat(" ", indent) + "}")
+	}
+}
+
+func (p *printer) endElement(out *strings.Builder, i, n int) {
+	if i < n-1 {
+		out.WriteString(",")
+	}
+	out.WriteString("\n")
+}
+
+// printFlat writes obj on a single line however long it gets.
+func (p *printer) printFlat(out *strings.Builder, obj object.Object, open map[object.Object]bool) {
+	if !isCollection(obj) {
+		p.printScalar(out, obj)
+		return
+	}
+	if open[obj] {
+		if obj.Type() == object.ARRAY_OBJ {
+			out.WriteString("[...]")
+		} else {
+			out.WriteString("{...}")
+		}
+		return
+	}
+	open[obj] = true
+	defer delete(open, obj)
+
+	switch obj := obj.(type) {
+	case *object.Array:
+		out.WriteString("[")
+		for i := 0; i < obj.Len(); i++ {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			p.printFlat(out, obj.At(i), open)
+		}
+		out.WriteString("]")
+	case *object.Hash:
+		out.WriteString("{")
+		for i, pair := range obj.Pairs() {
+			if i > 0 {
+				out.WriteString(", ")
+			}
+			p.printFlat(out, pair.Key, open)
+			out.WriteString(": ")
+			p.printFlat(out, pair.Value, open)
+		}
+		out.WriteString("}")
+	}
+}
+
+func isCollection(obj object.Object) bool {
+	switch obj.(type) {
+	case *object.Array, *object.Hash:
+		return true
+	}
+	return false
+}
+
+func (p *printer) printScalar(out *strings.Builder, obj object.Object) {
+	var text string
+	switch obj := obj.(type) {
+	case *object.String:
+		// Monkey strings have no escapes: a literal holds its text as it
+		// is up to the closing quote, so that is how the value is shown.
+		text = `"` + obj.Value + `"`
+	case *object.Function:
+		text = summary(obj)
+	case *object.Builtin:
+		text = "builtin " + obj.Name
+		if obj.Name == "" {
+			text = obj.Inspect()
+		}
+	default:
+		text = obj.Inspect()
+	}
+
+	color, ok := colors[obj.Type()]
+	if !p.color || !ok {
+		out.WriteString(text)
+		return
+	}
+	out.WriteString(color + text + colorReset)
+}
+
+// visibleLen is the number of characters s takes up on a terminal, leaving
+// out color codes.
+func visibleLen(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\x1b' {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		if s[i]&0xc0 != 0x80 {
+			n++
+		}
+	}
+	return n
+}
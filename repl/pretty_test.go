@@ -0,0 +1,100 @@
+package repl
+
+import (
+	"bytes"
+	"monkey/object"
+	"strings"
+	"testing"
+)
+
+func TestPrinter(t *testing.T) {
+	str := func(s string) object.Object { return &object.String{Value: s} }
+	num := func(n int64) object.Object { return &object.Integer{Value: n} }
+	hash := func(pairs ...object.Object) object.Object {
+		h := &object.Hash{}
+		for i := 0; i < len(pairs); i += 2 {
+			key := pairs[i].(object.Hashable).HashKey()
+			h = h.Set(key, object.HashPair{Key: pairs[i], Value: pairs[i+1]})
+		}
+		return h
+	}
+	array := func(elements ...object.Object) object.Object { return object.NewArray(elements) }
+	long := strings.Repeat("x", 35)
+
+	tests := []struct {
+		obj      object.Object
+		column   int
+		expected string
+	}{
+		{num(1), 0, "1"},
+		{str("1"), 0, `"1"`},
+		{str("tab\tline\n"), 0, "\"tab\tline\n\""},
+		{str("café \x00"), 0, "\"café \x00\""},
+		{&object.Null{}, 0, "null"},
+		{array(), 0, "[]"},
+		{array(num(1), str("two"), array(num(3))), 0, `[1, "two", [3]]`},
+		{hash(str("a"), array(num(1), num(2))), 0, `{"a": [1, 2]}`},
+		{array(str(long), str(long)), 0, `["` + long + `", "` + long + `"]`},
+		{array(str(long), str(long)), 5, "[\n" +
+			`  "` + long + `",` + "\n" +
+			`  "` + long + `"` + "\n" +
+			"]"},
+		{hash(str("key"), array(str(long), str(long), num(1))), 0, "{\n" +
+			`  "key": [` + "\n" +
+			`    "` + long + `",` + "\n" +
+			`    "` + long + `",` + "\n" +
+			"    1\n" +
+			"  ]\n" +
+			"}"},
+		{array(array(str(long), str(long), str(long)), num(2)), 0, "[\n" +
+			"  [\n" +
+			`    "` + long + `",` + "\n" +
+			`    "` + long + `",` + "\n" +
+			`    "` + long + `"` + "\n" +
+			"  ],\n" +
+			"  2\n" +
+			"]"},
+		{&object.Builtin{Name: "len"}, 0, "builtin len"},
+	}
+
+	p := &printer{width: printWidth}
+	for i, tt := range tests {
+		if got := p.Sprint(tt.obj, tt.column); got != tt.expected {
+			t.Errorf("tests[%d] wrong output, expected=\n%s\ngot=\n%s", i, tt.expected, got)
+		}
+	}
+}
+
+func TestPrinterCycles(t *testing.T) {
+	inner := object.NewArray([]object.Object{&object.Integer{Value: 1}})
+	shared := object.NewArray([]object.Object{inner, inner})
+
+	p := &printer{width: printWidth}
+	if got := p.Sprint(shared, 0); got != "[[1], [1]]" {
+		t.Errorf("a value appearing twice is not a cycle, got=%q", got)
+	}
+
+	// Persistent collections cannot be made to contain themselves, but a
+	// collection already being printed is cut short wherever it turns up.
+	var out strings.Builder
+	p.print(&out, shared, 0, 0, map[object.Object]bool{inner: true})
+	if out.String() != "[[...], [...]]" {
+		t.Errorf("wrong output for a cycle, got=%q", out.String())
+	}
+}
+
+func TestPrinterColor(t *testing.T) {
+	p := &printer{width: printWidth, color: true}
+	got := p.Sprint(object.NewArray([]object.Object{&object.Integer{Value: 1}, &object.String{Value: "a"}}), 0)
+	expected := "[" + colors[object.INTEGER_OBJ] + "1" + colorReset + ", " + colors[object.STRING_OBJ] + `"a"` + colorReset + "]"
+	if got != expected {
+		t.Errorf("wrong colored output, expected=%q, got=%q", expected, got)
+	}
+	if visibleLen(got) != len(`[1, "a"]`) {
+		t.Errorf("visibleLen counts color codes, got=%d", visibleLen(got))
+	}
+
+	if newPrinter(&bytes.Buffer{}).color {
+		t.Errorf("expected no color when output is not a terminal")
+	}
+}
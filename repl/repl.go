@@ -4,46 +4,336 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"monkey/ast"
+	"monkey/diag"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/resolver"
+	"monkey/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
 const PROMPT = ">> "
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+// CONTINUATION_PROMPT asks for the rest of an input left open at the end of
+// a line, inside brackets or a string.
+const CONTINUATION_PROMPT = ".. "
+
+// historyFile, in the user's home directory, keeps the lines entered at a
+// terminal from one session to the next.
+const historyFile = ".monkey_history"
+
+// session is the state a REPL keeps between inputs.
+type session struct {
+	out     io.Writer
+	env     *object.Environment
+	printer *printer
+	inputs  []string
+	exit    *int
+}
+
+// Start reads and evaluates input until it runs out or a program calls exit,
+// and returns the status code it was given, or 0. When in is a terminal
+// lines can be edited as they are typed, and are kept in a history.
+func Start(in io.Reader, out io.Writer) int {
+	return StartSession(in, out, "")
+}
+
+// StartSession is like Start, but first restores the session saved in file
+// with :save, unless file is empty.
+func StartSession(in io.Reader, out io.Writer, file string) int {
 	env := object.NewEnvironment()
+	env.Runtime().Stdout = out
+	env.Runtime().Stderr = out
+	s := &session{out: out, env: env, printer: newPrinter(out)}
 
-	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
+	var reader lineReader
+	if f, ok := in.(*os.File); ok && isTerminal(f.Fd()) {
+		env.Runtime().SetInput(f)
+		e := &editor{
+			in:       f,
+			out:      out,
+			raw:      func() (func(), error) { return makeRaw(f.Fd()) },
+			complete: s.complete,
+		}
+		if home, err := os.UserHomeDir(); err == nil {
+			e.loadHistory(filepath.Join(home, historyFile))
+		}
+		reader = e
+	} else {
+		buffered := bufio.NewReader(in)
+		env.Runtime().SetInput(buffered)
+		reader = &plainReader{reader: buffered, out: out}
+	}
+	if file != "" {
+		restoreCommand(s, file)
+	}
 
-		if !scanned {
-			return
+	var lines []string
+	for s.exit == nil {
+		prompt := PROMPT
+		if len(lines) > 0 {
+			prompt = CONTINUATION_PROMPT
 		}
+		line, err := reader.ReadLine(prompt)
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
+		if err == errInterrupted {
+			lines = nil
+			continue
+		}
+		if err != nil && line == "" {
+			if len(lines) > 0 {
+				io.WriteString(out, "\n")
+				s.run(strings.Join(lines, "\n"))
+			}
+			break
+		}
 
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+		// An empty line gives up on finishing the input, so that a stray
+		// bracket reports a parser error rather than waiting for ever.
+		if line != "" && lexer.Incomplete(input) {
 			continue
 		}
+		lines = nil
+		s.run(input)
+	}
+	if s.exit != nil {
+		return *s.exit
+	}
+	return 0
+}
+
+// complete returns the keywords, builtins and bindings of the session that
+// start with word, for completing it.
+func (s *session) complete(word string) []string {
+	names := append(token.Keywords(), evaluator.BuiltinNames()...)
+	for name := range s.env.Runtime().Builtins {
+		names = append(names, name)
+	}
+	names = append(names, s.env.Names()...)
+
+	var matches []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if strings.HasPrefix(name, word) && !seen[name] {
+			seen[name] = true
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// run evaluates input, or carries out the command it names if it starts
+// with a colon.
+func (s *session) run(input string) {
+	if !strings.HasPrefix(input, ":") {
+		if _, ok := s.eval("", input, true); ok {
+			s.record(input)
+		}
+		return
+	}
+
+	name, arg := input[1:], ""
+	if i := strings.IndexAny(name, " \t\n"); i >= 0 {
+		name, arg = name[:i], strings.TrimSpace(name[i:])
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			cmd.run(s, arg)
+			return
+		}
+	}
+	fmt.Fprintf(s.out, "unknown command :%s, try :help\n", name)
+}
+
+// eval evaluates source, from the file named filename if any, in the
+// session's environment and reports any error, along with the result if show
+// is set. It returns the result and whether there was no error.
+func (s *session) eval(filename, source string, show bool) (object.Object, bool) {
+	program, ok := s.parse(filename, source)
+	if !ok {
+		return nil, false
+	}
+	resolver.Resolve(program)
+
+	evaluated := evaluator.Eval(program, s.env)
+	if err, ok := evaluated.(*object.Error); ok {
+		if code, ok := err.ExitCode(); ok {
+			s.exit = &code
+			return nil, false
+		}
+		// Positions do not say which input a function came from, so the
+		// source is shown only for errors raised at the top level of this
+		// input, or anywhere in a file being loaded.
+		d := evaluator.Diagnostic(err)
+		var src []byte
+		if filename != "" && d.Span.Start.Filename == filename || filename == "" && len(err.Stack) == 1 {
+			src = []byte(source)
+		}
+		diag.Fprint(s.out, src, d, s.printer.color)
+		return nil, false
+	}
+	if evaluated != nil && show {
+		io.WriteString(s.out, s.printer.Sprint(evaluated, 0)+"\n")
+	}
+	return evaluated, true
+}
+
+// record keeps source that evaluated without error for :save. Each input
+// ends in a semicolon, so that when they are read back together one cannot
+// run into the next, as a call or index. After a trailing comment the
+// semicolon goes on a line of its own, since the comment runs to the end of
+// its line.
+func (s *session) record(source string) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return
+	}
+	l := lexer.New(source)
+	var last token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		last = tok
+	}
+	if last.Type != token.SEMICOLON && last.Type != "" {
+		if comments := l.Comments(); len(comments) > 0 && after(comments[len(comments)-1].Pos, last.Pos) {
+			source += "\n"
+		}
+		source += ";"
+	}
+	s.inputs = append(s.inputs, source)
+}
+
+func after(a, b token.Position) bool {
+	return a.Line > b.Line || a.Line == b.Line && a.Column > b.Column
+}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+// parse parses source, showing any errors under the lines they are on.
+func (s *session) parse(filename, source string) (*ast.Program, bool) {
+	p := parser.New(lexer.NewFile(filename, source))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		for _, d := range p.Diagnostics() {
+			diag.Fprint(s.out, []byte(source), d, s.printer.color)
 		}
+		return nil, false
+	}
+	return program, true
+}
+
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(s *session, arg string)
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"tokens", ":tokens <source>", "list the tokens the lexer reads from source", tokensCommand},
+		{"ast", ":ast <source>", "show the tree the parser builds from source", astCommand},
+		{"env", ":env", "list the bindings made in this session", envCommand},
+		{"type", ":type <expression>", "evaluate expression and show the type of its value", typeCommand},
+		{"load", ":load <file>", "evaluate a file into this session", loadCommand},
+		{"reset", ":reset", "forget every binding made in this session", resetCommand},
+		{"save", ":save <file>", "write the inputs evaluated without error to a file", saveCommand},
+		{"restore", ":restore <file>", "start afresh from a session written by :save", restoreCommand},
+		{"help", ":help", "list these commands", helpCommand},
+	}
+}
+
+func tokensCommand(s *session, arg string) {
+	l := lexer.New(arg)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%s\t%s\t%q\n", tok.Pos, tok.Type, tok.Literal)
+	}
+}
+
+func astCommand(s *session, arg string) {
+	if program, ok := s.parse("", arg); ok {
+		ast.Fprint(s.out, program)
+	}
+}
+
+func envCommand(s *session, arg string) {
+	for _, name := range s.env.Names() {
+		value, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, s.printer.Sprint(value, len(name)+3))
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+// summary is a one-line Inspect of obj, giving just the parameters of a
+// function rather than its whole body.
+func summary(obj object.Object) string {
+	fn, ok := obj.(*object.Function)
+	if !ok {
+		return obj.Inspect()
+	}
+	var params []string
+	for _, p := range fn.Parameters {
+		params = append(params, p.String())
+	}
+	return "fn(" + strings.Join(params, ", ") + ")"
+}
+
+func typeCommand(s *session, arg string) {
+	if evaluated, ok := s.eval("", arg, false); ok && evaluated != nil {
+		fmt.Fprintln(s.out, evaluated.Type())
+	}
+}
+
+func loadCommand(s *session, arg string) {
+	source, err := os.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	if _, ok := s.eval(arg, string(source), false); ok {
+		s.record(string(source))
+	}
+}
+
+func resetCommand(s *session, arg string) {
+	s.env = object.NewEnvironmentWithRuntime(s.env.Runtime())
+	s.inputs = nil
+}
+
+func saveCommand(s *session, arg string) {
+	if arg == "" {
+		fmt.Fprintln(s.out, "usage: :save <file>")
+		return
+	}
+	var source strings.Builder
+	for _, input := range s.inputs {
+		source.WriteString(input + "\n")
+	}
+	if err := os.WriteFile(arg, []byte(source.String()), 0644); err != nil {
+		fmt.Fprintln(s.out, err)
+		return
+	}
+	noun := "inputs"
+	if len(s.inputs) == 1 {
+		noun = "input"
+	}
+	fmt.Fprintf(s.out, "saved %d %s to %s\n", len(s.inputs), noun, arg)
+}
+
+func restoreCommand(s *session, arg string) {
+	resetCommand(s, "")
+	loadCommand(s, arg)
+}
+
+func helpCommand(s *session, arg string) {
+	for _, cmd := range commands {
+		fmt.Fprintf(s.out, "%-20s %s\n", cmd.usage, cmd.help)
 	}
 }
@@ -0,0 +1,139 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2\n", ">> 3\n>> "},
+		{
+			"let add = fn(x, y) {\n  x + y\n};\nadd(1, 2)\n",
+			">> .. .. >> 3\n>> ",
+		},
+		{"[1,\n2]\n", ">> .. [1, 2]\n>> "},
+		{"\"a\nb\"\n", ">> .. \"a\nb\"\n>> "},
+		{"puts(\"${len(\"ab\n\")}\")\n", ">> .. 3\nnull\n>> "},
+		{"(1 + \n\n2\n", ">> .. 2:1: error: no prefix parse function for EOF found\n 2 | \n   | ^\n>> 2\n>> "},
+		{"len([1,\n2, 3])", ">> .. 3\n>> "},
+		{"len([1,\n2, 3]", ">> .. .. \n2:6: error: expected ')' to close call started at 1:4\n 2 | 2, 3]\n   |      ^\n   = help: insert ')'\n"},
+	}
+
+	for i, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input), &out)
+		if out.String() != tt.expected {
+			t.Errorf("tests[%d] wrong output, expected=%q, got=%q", i, tt.expected, out.String())
+		}
+	}
+}
+
+func TestStartExit(t *testing.T) {
+	var out bytes.Buffer
+	code := Start(strings.NewReader("1\ntry { exit(3) } catch (e) { 0 }\n2\n"), &out)
+	if code != 3 {
+		t.Errorf("wrong exit code, expected=3, got=%d", code)
+	}
+	if out.String() != ">> 1\n>> " {
+		t.Errorf("wrong output, got=%q", out.String())
+	}
+}
+
+func TestCommands(t *testing.T) {
+	file, err := os.CreateTemp("", "repl*.mk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("let double = fn(x) { x * 2 };\nlet ten = double(5);")
+	file.Close()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{":tokens let x = \"a\";", "1:1\tLET\t\"let\"\n1:5\tIDENT\t\"x\"\n1:7\t=\t\"=\"\n1:9\tSTRING\t\"a\"\n1:12\t;\t\";\"\n"},
+		{":ast -x", "Program 1:1\n  ExpressionStatement 1:1\n    PrefixExpression - 1:1\n      Right: Identifier x 1:2\n"},
+		{":ast fn(x) {\nx\n}", "Program 1:1\n  ExpressionStatement 1:1\n    FunctionLiteral 1:1\n      Parameters[0]: Identifier x 1:4\n      Body: BlockStatement 1:7\n        ExpressionStatement 2:1\n          Identifier x 2:1\n"},
+		{":ast let = 1", "1:5: error: expected next token to be IDENT, got = instead\n 1 | let = 1\n   |     ^\n"},
+		{"let b = [1]; let a = fn(x, y) { x };\n:env", "a = fn(x, y)\nb = [1]\n"},
+		{":type 1 + 1", "INTEGER\n"},
+		{":type {}", "HASH\n"},
+		{":type x", "1:1: error: identifier not found: x (NameError)\n 1 | x\n   | ^\n   = note: at <main> (1:1)\n"},
+		{":load " + file.Name() + "\nten", "10\n"},
+		{":load missing.mk", "open missing.mk: no such file or directory\n"},
+		{"let x = 1;\n:reset\nx", "1:1: error: identifier not found: x (NameError)\n 1 | x\n   | ^\n   = note: at <main> (1:1)\n"},
+		{"let f = fn() {\n  len(1)\n};\nf()", "2:3: error: argument to `len` not supported, got INTEGER (TypeError)\n = note: at f (2:3)\n = note: at <main> (1:1)\n"},
+		{":nope", "unknown command :nope, try :help\n"},
+	}
+
+	for i, tt := range tests {
+		var out bytes.Buffer
+		Start(strings.NewReader(tt.input+"\n"), &out)
+		got := strings.ReplaceAll(out.String(), CONTINUATION_PROMPT, "")
+		got = strings.ReplaceAll(got, PROMPT, "")
+		if got != tt.expected {
+			t.Errorf("tests[%d] wrong output, expected=%q, got=%q", i, tt.expected, got)
+		}
+	}
+}
+
+func TestHelpCommand(t *testing.T) {
+	var out bytes.Buffer
+	Start(strings.NewReader(":help\n"), &out)
+	for _, cmd := range commands {
+		if !strings.Contains(out.String(), cmd.usage) {
+			t.Errorf("help does not mention %s, got=%q", cmd.usage, out.String())
+		}
+	}
+}
+
+func TestSaveAndRestore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.mk")
+
+	input := "let add = fn(x, y) {\n  x + y\n}\nadd(1, nope)\nlet three = add(1, 2);\n:type three\nlet one = 1 // first\n// nothing\n:save " + file + "\n"
+	var out bytes.Buffer
+	Start(strings.NewReader(input), &out)
+	if !strings.Contains(out.String(), "saved 4 inputs to "+file) {
+		t.Fatalf("expected a confirmation, got=%q", out.String())
+	}
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "let add = fn(x, y) {\n  x + y\n};\nlet three = add(1, 2);\nlet one = 1 // first\n;\n// nothing\n"
+	if string(content) != expected {
+		t.Fatalf("wrong session file, expected=%q, got=%q", expected, content)
+	}
+
+	tests := []struct {
+		start    func(out *bytes.Buffer)
+		expected string
+	}{
+		{func(out *bytes.Buffer) {
+			Start(strings.NewReader("let three = 0;\n:restore "+file+"\nadd(three, one)\n"), out)
+		}, ">> >> >> 4\n>> "},
+		{func(out *bytes.Buffer) {
+			StartSession(strings.NewReader("add(three, one)\n"), out, file)
+		}, ">> 4\n>> "},
+		{func(out *bytes.Buffer) {
+			StartSession(strings.NewReader(":save "+file+".again\n"), out, file)
+		}, ">> saved 1 input to " + file + ".again\n>> "},
+	}
+
+	for i, tt := range tests {
+		var out bytes.Buffer
+		tt.start(&out)
+		if out.String() != tt.expected {
+			t.Errorf("tests[%d] wrong output, expected=%q, got=%q", i, tt.expected, out.String())
+		}
+	}
+}
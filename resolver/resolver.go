@@ -0,0 +1,184 @@
+// Package resolver works out where each variable of a program lives before
+// it is evaluated, so the evaluator can find locals by index rather than by
+// walking environments and comparing names. It also makes the objects that
+// integer and string literals evaluate to, so that evaluating a literal
+// allocates nothing.
+//
+// Functions and catch blocks get an environment of their own at run time and
+// so a scope here; if blocks share the environment around them. Globals stay
+// unresolved, since a REPL or host can add more of them at any time.
+package resolver
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+type scope struct {
+	names   []string
+	slots   map[string]int
+	pending []reference
+	outer   *scope
+}
+
+// reference is an identifier waiting to be resolved. Its scope may still
+// declare the name further on, so references are only settled when the scope
+// ends.
+type reference struct {
+	ident *ast.Identifier
+	depth int
+}
+
+type resolver struct {
+	scope *scope
+}
+
+// Resolve annotates the identifiers in program with where to find them and
+// records the slots each function and catch block needs.
+func Resolve(program *ast.Program) {
+	r := &resolver{}
+	r.resolve(program)
+}
+
+func (r *resolver) resolve(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			r.resolve(s)
+		}
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			r.resolve(s)
+		}
+	case *ast.ExpressionStatement:
+		r.resolve(node.Expression)
+	case *ast.LetStatement:
+		r.resolve(node.Value)
+		r.declare(node.Name)
+	case *ast.ReturnStatement:
+		r.resolve(node.ReturnValue)
+	case *ast.ThrowStatement:
+		r.resolve(node.Value)
+	case *ast.Identifier:
+		r.reference(node)
+	case *ast.IntegerLiteral:
+		node.Object = &object.Integer{Value: node.Value}
+	case *ast.StringLiteral:
+		node.Object = &object.String{Value: node.Value}
+	case *ast.PrefixExpression:
+		r.resolve(node.Right)
+	case *ast.InfixExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Right)
+	case *ast.IfExpression:
+		r.resolve(node.Condition)
+		r.resolve(node.Consequence)
+		if node.Alternative != nil {
+			r.resolve(node.Alternative)
+		}
+	case *ast.TryExpression:
+		r.resolve(node.Block)
+		if node.Catch != nil {
+			r.push()
+			r.addSlot(node.Parameter)
+			r.resolve(node.Catch)
+			node.CatchLocals = r.pop()
+		}
+		if node.Finally != nil {
+			r.resolve(node.Finally)
+		}
+	case *ast.FunctionLiteral:
+		r.push()
+		for _, param := range node.Parameters {
+			r.addSlot(param)
+		}
+		r.resolve(node.Body)
+		node.Locals = r.pop()
+	case *ast.CallExpression:
+		r.resolve(node.Function)
+		for _, arg := range node.Arguments {
+			r.resolve(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			r.resolve(el)
+		}
+	case *ast.HashLiteral:
+		for key, value := range node.Pairs {
+			r.resolve(key)
+			r.resolve(value)
+		}
+	case *ast.IndexExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Index)
+	case *ast.SliceExpression:
+		r.resolve(node.Left)
+		if node.Start != nil {
+			r.resolve(node.Start)
+		}
+		if node.End != nil {
+			r.resolve(node.End)
+		}
+	case *ast.TemplateLiteral:
+		for _, exp := range node.Expressions {
+			r.resolve(exp)
+		}
+	}
+}
+
+func (r *resolver) push() {
+	r.scope = &scope{slots: make(map[string]int), outer: r.scope}
+}
+
+// pop settles the references left in the current scope, passing those it
+// does not declare out to the enclosing scope, and returns its slot names.
+func (r *resolver) pop() []string {
+	s := r.scope
+	r.scope = s.outer
+	for _, ref := range s.pending {
+		if slot, ok := s.slots[ref.ident.Value]; ok {
+			ref.ident.Local = true
+			ref.ident.Depth = ref.depth
+			ref.ident.Slot = slot
+		} else if s.outer != nil {
+			s.outer.pending = append(s.outer.pending, reference{ref.ident, ref.depth + 1})
+		} else {
+			ref.ident.Local = false
+		}
+	}
+	return s.names
+}
+
+func (r *resolver) declare(ident *ast.Identifier) {
+	if r.scope == nil {
+		ident.Local = false
+		return
+	}
+	slot, ok := r.scope.slots[ident.Value]
+	if !ok {
+		r.addSlot(ident)
+		return
+	}
+	ident.Local = true
+	ident.Depth = 0
+	ident.Slot = slot
+}
+
+// addSlot gives ident a new slot in the current scope. Parameters always get
+// one, even when repeated, since arguments are assigned to slots in order.
+func (r *resolver) addSlot(ident *ast.Identifier) {
+	slot := len(r.scope.names)
+	r.scope.slots[ident.Value] = slot
+	r.scope.names = append(r.scope.names, ident.Value)
+	ident.Local = true
+	ident.Depth = 0
+	ident.Slot = slot
+}
+
+func (r *resolver) reference(ident *ast.Identifier) {
+	if r.scope == nil {
+		ident.Local = false
+		return
+	}
+	r.scope.pending = append(r.scope.pending, reference{ident: ident})
+}
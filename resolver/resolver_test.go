@@ -0,0 +1,123 @@
+package resolver
+
+import (
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"reflect"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	input := `
+let g = 1;
+let outer = fn(a, b) {
+	let inner = fn(c) { a + c + g + later };
+	let later = 2;
+	try { inner(b) } catch (e) { let d = e; d + a }
+};
+g`
+
+	program := parse(t, input)
+	Resolve(program)
+
+	one := program.Statements[0].(*ast.LetStatement).Value.(*ast.IntegerLiteral)
+	if integer, ok := one.Object.(*object.Integer); !ok || integer.Value != 1 {
+		t.Errorf("wrong object for literal 1, got=%v", one.Object)
+	}
+
+	outer := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if !reflect.DeepEqual(outer.Locals, []string{"a", "b", "inner", "later"}) {
+		t.Errorf("wrong locals for outer, got=%q", outer.Locals)
+	}
+	inner := outer.Body.Statements[0].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if !reflect.DeepEqual(inner.Locals, []string{"c"}) {
+		t.Errorf("wrong locals for inner, got=%q", inner.Locals)
+	}
+	try := outer.Body.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.TryExpression)
+	if !reflect.DeepEqual(try.CatchLocals, []string{"e", "d"}) {
+		t.Errorf("wrong locals for catch, got=%q", try.CatchLocals)
+	}
+
+	tests := []struct {
+		ident *ast.Identifier
+		local bool
+		depth int
+		slot  int
+	}{
+		{program.Statements[0].(*ast.LetStatement).Name, false, 0, 0},
+		{program.Statements[2].(*ast.ExpressionStatement).Expression.(*ast.Identifier), false, 0, 0},
+		{outer.Body.Statements[1].(*ast.LetStatement).Name, true, 0, 3},
+		{identifiers(inner.Body)[0], true, 1, 0},
+		{identifiers(inner.Body)[1], true, 0, 0},
+		{identifiers(inner.Body)[2], false, 0, 0},
+		{identifiers(inner.Body)[3], true, 1, 3},
+		{identifiers(try.Block)[1], true, 0, 1},
+		{identifiers(try.Catch)[0], true, 0, 0},
+		{identifiers(try.Catch)[1], true, 0, 1},
+		{identifiers(try.Catch)[2], true, 1, 0},
+	}
+
+	for i, tt := range tests {
+		if tt.ident.Local != tt.local || tt.ident.Depth != tt.depth || tt.ident.Slot != tt.slot {
+			t.Errorf("tests[%d] %s: expected local=%t depth=%d slot=%d, got local=%t depth=%d slot=%d",
+				i, tt.ident.Value, tt.local, tt.depth, tt.slot, tt.ident.Local, tt.ident.Depth, tt.ident.Slot)
+		}
+	}
+}
+
+func TestResolveRepeatedParameters(t *testing.T) {
+	program := parse(t, `fn(a, a) { a }`)
+	Resolve(program)
+
+	fn := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if !reflect.DeepEqual(fn.Locals, []string{"a", "a"}) {
+		t.Fatalf("each parameter needs a slot, got=%q", fn.Locals)
+	}
+	if a := identifiers(fn.Body)[0]; a.Slot != 1 {
+		t.Fatalf("expected the last parameter to win, got slot %d", a.Slot)
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %q", p.Errors())
+	}
+	return program
+}
+
+// identifiers lists the identifiers referenced in block in source order,
+// without descending into function literals or catch blocks.
+func identifiers(block *ast.BlockStatement) []*ast.Identifier {
+	var idents []*ast.Identifier
+	var visit func(node ast.Node)
+	visit = func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.BlockStatement:
+			for _, s := range node.Statements {
+				visit(s)
+			}
+		case *ast.ExpressionStatement:
+			visit(node.Expression)
+		case *ast.LetStatement:
+			visit(node.Value)
+		case *ast.Identifier:
+			idents = append(idents, node)
+		case *ast.InfixExpression:
+			visit(node.Left)
+			visit(node.Right)
+		case *ast.CallExpression:
+			visit(node.Function)
+			for _, arg := range node.Arguments {
+				visit(arg)
+			}
+		case *ast.TryExpression:
+			visit(node.Block)
+		}
+	}
+	visit(block)
+	return idents
+}